@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monitoring-agent-client-check-nt-replacement/internal/countermap"
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"monitoring-agent-client-check-nt-replacement/internal/httpclient"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// agentConnection holds everything required to query the os_specific API of
+// a monitoring-agent.
+type agentConnection struct {
+	httpClient httpclient.Interface
+	url        string
+	username   string
+	password   string
+
+	// counterMap translates the English names of counter paths into the
+	// names of a localized Windows installation, if set.
+	counterMap *countermap.Map
+}
+
+func newAgentConnection(httpClient httpclient.Interface, hostname string, port int, username string, password string) agentConnection {
+	return agentConnection{
+		httpClient: httpClient,
+		url:        fmt.Sprintf("https://%s:%d/v1/os_specific", hostname, port),
+		username:   username,
+		password:   password,
+	}
+}
+
+// connectionFlags are the flags describing how to reach monitoring-agent,
+// which are shared by the plugin and the serve-nsclient command.
+type connectionFlags struct {
+	hostname              *string
+	port                  *int
+	username              *string
+	password              *string
+	cacertificateFilePath *string
+	certificateFilePath   *string
+	privateKeyFilePath    *string
+	timeoutString         *string
+	makeInsecure          *bool
+	counterMapFilePath    *string
+}
+
+func addConnectionFlags(flags *flag.FlagSet, defaultHostname string) connectionFlags {
+	return connectionFlags{
+		hostname:              flags.String("host", defaultHostname, "hostname or ip"),
+		port:                  flags.Int("port", 9000, "port number"),
+		username:              flags.String("username", os.Getenv("MONITORING_AGENT_USERNAME"), "username"),
+		password:              flags.String("password", os.Getenv("MONITORING_AGENT_PASSWORD"), "password"),
+		cacertificateFilePath: flags.String("cacert", os.Getenv("MONITORING_AGENT_CA_CERTIFICATE_PATH"), "CA certificate"),
+		certificateFilePath:   flags.String("certificate", os.Getenv("MONITORING_AGENT_CLIENT_CERTIFICATE_PATH"), "certificate file"),
+		privateKeyFilePath:    flags.String("key", os.Getenv("MONITORING_AGENT_CLIENT_KEY_PATH"), "key file"),
+		timeoutString:         flags.String("timeout", "10s", "timeout (e.g. 10s)"),
+		makeInsecure:          flags.Bool("insecure", false, "ignore TLS Certificate checks"),
+		counterMapFilePath:    flags.String("counter-map", "", "file translating English counter names for localized Windows, a line per counter in the form index,english,localized"),
+	}
+}
+
+func (agent connectionFlags) validate() error {
+	if *agent.hostname == "" {
+		return fmt.Errorf("hostname is not set")
+	}
+	if *agent.password == "" {
+		return fmt.Errorf("password is not set")
+	}
+	return nil
+}
+
+// connect configures httpClient to talk to monitoring-agent.
+func (agent connectionFlags) connect(httpClient httpclient.Interface, timeout time.Duration) (agentConnection, error) {
+	httpClient.SetTimeout(timeout)
+
+	transport, err := newTransport(*agent.cacertificateFilePath, *agent.certificateFilePath, *agent.privateKeyFilePath, *agent.makeInsecure)
+	if err != nil {
+		return agentConnection{}, err
+	}
+
+	httpClient.SetTransport(transport)
+
+	connection := newAgentConnection(httpClient, *agent.hostname, *agent.port, *agent.username, *agent.password)
+
+	if *agent.counterMapFilePath != "" {
+		connection.counterMap, err = countermap.LoadFile(*agent.counterMapFilePath)
+		if err != nil {
+			return agentConnection{}, fmt.Errorf("error loading counter map %s", err.Error())
+		}
+	}
+
+	return connection, nil
+}
+
+// newTransport builds the TLS transport used to talk to monitoring-agent,
+// optionally loading a client certificate pair and a CA certificate.
+func newTransport(cacertificateFilePath string, certificateFilePath string, privateKeyFilePath string, makeInsecure bool) (*http.Transport, error) {
+	transport := new(http.Transport)
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: makeInsecure,
+	}
+
+	if certificateFilePath != "" && privateKeyFilePath != "" {
+		certificateToLoad, err := tls.LoadX509KeyPair(certificateFilePath, privateKeyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading certificate pair %s", err.Error())
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificateToLoad}
+	}
+
+	if cacertificateFilePath != "" {
+		caCertificate, err := ioutil.ReadFile(cacertificateFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading ca certificate %s", err.Error())
+		}
+		CACertificatePool := x509.NewCertPool()
+		CACertificatePool.AppendCertsFromPEM(caCertificate)
+		transport.TLSClientConfig.RootCAs = CACertificatePool
+	}
+
+	return transport, nil
+}
+
+// queryCounter requests a single counter path from the os_specific API.
+func (connection agentConnection) queryCounter(counterPath string) (CounterResult, error) {
+	var decodedResponse CounterResult
+
+	restRequest := map[string]interface{}{
+		"CounterPath": connection.localize(counterPath),
+	}
+
+	byteArray, _ := json.Marshal(restRequest)
+	byteArrayBuffer := bytes.NewBuffer(byteArray)
+
+	req, err := http.NewRequest(http.MethodPost, connection.url, byteArrayBuffer)
+	if err != nil {
+		panic(fmt.Errorf("got http request error %s", err.Error()))
+	}
+	req.SetBasicAuth(connection.username, connection.password)
+
+	response, err := connection.httpClient.Do(req)
+
+	if err != nil {
+		return decodedResponse, fmt.Errorf("got httpClient error %s", err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		errorBodyContent, _ := ioutil.ReadAll(response.Body)
+		return decodedResponse, fmt.Errorf("Response code: %d\n%s", response.StatusCode, errorBodyContent)
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	decoder.DisallowUnknownFields()
+	decoder.Decode(&decodedResponse)
+
+	return decodedResponse, nil
+}
+
+// localize translates the object and counter names of a path into the names
+// used by a localized Windows installation, when a counter map is loaded.
+// Results keep their instance names, so the labels built from them and from
+// the English path stay the same whatever the language.
+func (connection agentConnection) localize(counterPath string) string {
+	if connection.counterMap == nil {
+		return counterPath
+	}
+
+	parsed, err := counterpath.Parse(counterPath)
+	if err != nil {
+		return counterPath
+	}
+	return connection.counterMap.Localize(parsed).String()
+}
+
+// queryCounterValue requests a counter path which is expected to return a
+// single numeric value, such as a _Total instance or a counter without
+// instances.
+func (connection agentConnection) queryCounterValue(counterPath string) (float64, error) {
+	decodedResponse, err := connection.queryCounter(counterPath)
+	if err != nil {
+		return 0, err
+	}
+	if len(decodedResponse.Results) == 0 {
+		return 0, fmt.Errorf("no value returned for %s", counterPath)
+	}
+
+	value, err := strconv.ParseFloat(decodedResponse.Results[0].Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q returned for %s is not numeric", decodedResponse.Results[0].Value, counterPath)
+	}
+
+	return value, nil
+}
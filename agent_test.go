@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/httpclient"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMockConnection returns a connection to an agent which answers each
+// counter path, compared ignoring case, with its results and any other path
+// with a 404.
+func newMockConnection(responses map[string][]CounterResultItem) agentConnection {
+	client := httpclient.NewMockHTTPClient("", http.StatusOK)
+	client.DoFunc = func(r *http.Request) (*http.Response, error) {
+		var request struct{ CounterPath string }
+		json.NewDecoder(r.Body).Decode(&request)
+
+		for path, results := range responses {
+			if strings.EqualFold(path, request.CounterPath) {
+				body, _ := json.Marshal(CounterResult{Results: results})
+				return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(body)), StatusCode: http.StatusOK}, nil
+			}
+		}
+		return &http.Response{Body: ioutil.NopCloser(strings.NewReader("counter not found")), StatusCode: http.StatusNotFound}, nil
+	}
+	return newAgentConnection(client, "localhost", 9000, "username", "password")
+}
+
+// runCheck runs a check against a plugin and returns the plugin output along
+// with the exit code and the error returned by the check.
+func runCheck(check func(plugin *nagios.Plugin) error) (string, int, error) {
+	var output bytes.Buffer
+	plugin := nagios.Plugin{ExitStatusCode: nagios.StateOKExitCode}
+	plugin.SetOutputTarget(&output)
+	plugin.SkipOSExit()
+
+	err := check(&plugin)
+	plugin.ReturnCheckResults()
+	return output.String(), plugin.ExitStatusCode, err
+}
+
+func TestAgent(t *testing.T) {
+	connection := newMockConnection(map[string][]CounterResultItem{
+		`\System\System Up Time`: {{CounterName: "System Up Time", Value: "300"}},
+		`\Test\Text`:             {{CounterName: "Text", Value: "n/a"}},
+		`\Test\Empty`:            {},
+	})
+
+	t.Run("Single values are returned as numbers", func(t *testing.T) {
+		value, err := connection.queryCounterValue(`\System\System Up Time`)
+		assert.Nil(t, err)
+		assert.Equal(t, 300.0, value)
+	})
+
+	t.Run("Missing and non-numeric values are reported", func(t *testing.T) {
+		_, err := connection.queryCounterValue(`\Test\Text`)
+		assert.Contains(t, err.Error(), "is not numeric")
+
+		_, err = connection.queryCounterValue(`\Test\Empty`)
+		assert.Contains(t, err.Error(), "no value returned")
+
+		_, err = connection.queryCounterValue(`\Test\Missing`)
+		assert.Contains(t, err.Error(), "Response code: 404")
+	})
+}
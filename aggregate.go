@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const aggregateCount = "count"
+
+// aggregateFunctions reduce the values of every instance of a counter to a
+// single value. They are never called without any values, except for count.
+var aggregateFunctions = map[string]func(values []float64) float64{
+	"sum": func(values []float64) float64 {
+		total := 0.0
+		for _, value := range values {
+			total += value
+		}
+		return total
+	},
+	"avg": func(values []float64) float64 {
+		total := 0.0
+		for _, value := range values {
+			total += value
+		}
+		return total / float64(len(values))
+	},
+	"min": func(values []float64) float64 {
+		minimum := values[0]
+		for _, value := range values[1:] {
+			minimum = math.Min(minimum, value)
+		}
+		return minimum
+	},
+	"max": func(values []float64) float64 {
+		maximum := values[0]
+		for _, value := range values[1:] {
+			maximum = math.Max(maximum, value)
+		}
+		return maximum
+	},
+	aggregateCount: func(values []float64) float64 {
+		return float64(len(values))
+	},
+	"median": func(values []float64) float64 {
+		sorted := sortedCopy(values)
+		middle := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[middle-1] + sorted[middle]) / 2
+		}
+		return sorted[middle]
+	},
+	// p95 uses the nearest rank method.
+	"p95": func(values []float64) float64 {
+		sorted := sortedCopy(values)
+		rank := int(math.Ceil(0.95 * float64(len(sorted))))
+		return sorted[rank-1]
+	},
+}
+
+func sortedCopy(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// validateAggregate checks the name of an aggregate function, an empty name
+// meaning that every instance is checked on its own.
+func validateAggregate(function string) error {
+	if _, ok := aggregateFunctions[function]; ok || function == "" {
+		return nil
+	}
+
+	var names []string
+	for name := range aggregateFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("aggregate must be one of %s but got %q", strings.Join(names, ", "), function)
+}
+
+// aggregateInstances reduces the instances of a counter to a single result,
+// named after the aggregate function.
+func aggregateInstances(function string, instances []CounterResultItem, counterPath string) (CounterResultItem, error) {
+	values := make([]float64, len(instances))
+	for i, instance := range instances {
+		value, err := strconv.ParseFloat(instance.Value, 64)
+		if err != nil && function != aggregateCount {
+			return CounterResultItem{}, fmt.Errorf("value %q returned for %s instance %s is not numeric", instance.Value, counterPath, instance.InstanceName)
+		}
+		values[i] = value
+	}
+
+	return CounterResultItem{
+		CounterName:  counterPath,
+		InstanceName: function,
+		Value:        strconv.FormatFloat(aggregateFunctions[function](values), 'f', -1, 64),
+	}, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	instances := func(values ...string) []CounterResultItem {
+		items := make([]CounterResultItem, len(values))
+		for i, value := range values {
+			items[i] = CounterResultItem{InstanceName: string(rune('a' + i)), Value: value}
+		}
+		return items
+	}
+
+	t.Run("Instances are reduced to a single value", func(t *testing.T) {
+		tests := []struct {
+			function string
+			values   []string
+			expected string
+		}{
+			{"sum", []string{"1", "2", "3.5"}, "6.5"},
+			{"avg", []string{"1", "2", "6"}, "3"},
+			{"min", []string{"4", "-1", "2"}, "-1"},
+			{"max", []string{"4", "9", "2"}, "9"},
+			{"count", []string{"4", "9", "2"}, "3"},
+			{"count", nil, "0"},
+			{"median", []string{"5", "1", "3"}, "3"},
+			{"median", []string{"4", "1", "3", "2"}, "2.5"},
+			{"p95", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19", "100"}, "19"},
+			{"p95", []string{"7"}, "7"},
+		}
+
+		for _, test := range tests {
+			aggregated, err := aggregateInstances(test.function, instances(test.values...), `\X(*)\Y`)
+			assert.Nil(t, err, test)
+			assert.Equal(t, test.expected, aggregated.Value, test)
+			assert.Equal(t, test.function, aggregated.InstanceName, test)
+		}
+	})
+
+	t.Run("Values which are not numeric are reported unless counted", func(t *testing.T) {
+		_, err := aggregateInstances("sum", instances("1", "n/a"), `\X(*)\Y`)
+		assert.EqualError(t, err, `value "n/a" returned for \X(*)\Y instance b is not numeric`)
+
+		aggregated, err := aggregateInstances(aggregateCount, instances("1", "n/a"), `\X(*)\Y`)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", aggregated.Value)
+	})
+
+	t.Run("Aggregate names are validated", func(t *testing.T) {
+		assert.Nil(t, validateAggregate(""))
+		assert.Nil(t, validateAggregate("p95"))
+		assert.EqualError(t, validateAggregate("mean"), `aggregate must be one of avg, count, max, median, min, p95, sum but got "mean"`)
+	})
+}
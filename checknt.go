@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// check_nt variables understood by the -v flag.
+const (
+	checkNtVariableCounter       = "COUNTER"
+	checkNtVariableCPULoad       = "CPULOAD"
+	checkNtVariableMemUse        = "MEMUSE"
+	checkNtVariableUsedDiskSpace = "USEDDISKSPACE"
+	checkNtVariableUptime        = "UPTIME"
+	checkNtVariableProcState     = "PROCSTATE"
+	checkNtVariableInstances     = "INSTANCES"
+)
+
+// checkNtVariables are all of the check_nt variables which are supported.
+var checkNtVariables = []string{
+	checkNtVariableCounter,
+	checkNtVariableCPULoad,
+	checkNtVariableMemUse,
+	checkNtVariableUsedDiskSpace,
+	checkNtVariableUptime,
+	checkNtVariableProcState,
+	checkNtVariableInstances,
+}
+
+// checkNtDisplayShowAll is the only value check_nt accepts for -d.
+const checkNtDisplayShowAll = "SHOWALL"
+
+// addCheckNtConnectionFlags adds the check_nt flags for the connection to
+// the agent. check_nt's -p is the port of NSClient, not of the agent, so it is
+// accepted to keep existing command definitions working but otherwise
+// ignored.
+func addCheckNtConnectionFlags(flags *flag.FlagSet, agent connectionFlags) {
+	flags.StringVar(agent.hostname, "H", *agent.hostname, "hostname or ip (check_nt compatible)")
+	flags.Int("p", 0, "NSClient port number, ignored in favour of -port (check_nt compatible)")
+	flags.StringVar(agent.password, "s", *agent.password, "password (check_nt compatible)")
+}
+
+// splitCheckNtParams splits the comma separated value of check_nt's -l flag
+// into its parts. Nagios command definitions frequently quote each part
+// individually (i.e. "\\Memory\\Committed Bytes","Committed"), so any
+// surrounding double quotes are removed from each part.
+func splitCheckNtParams(params string) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, character := range params {
+		switch {
+		case character == '"':
+			inQuotes = !inQuotes
+		case character == ',' && !inQuotes:
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(character)
+		}
+	}
+
+	return append(parts, strings.TrimSpace(current.String()))
+}
+
+// checkNtCounterParams are the fields of the -l value of a check_nt COUNTER
+// check, counter,description,unit,min,max, of which all but the counter are
+// optional.
+type checkNtCounterParams struct {
+	path        string
+	description string
+	unit        string
+	min         string
+	max         string
+}
+
+// parseCheckNtCounterParams splits the -l value of a check_nt COUNTER check
+// into its fields. The minimum and maximum must be numbers when given.
+func parseCheckNtCounterParams(params string) (checkNtCounterParams, error) {
+	parts := splitCheckNtParams(params)
+	if len(parts) > 5 {
+		return checkNtCounterParams{}, fmt.Errorf("COUNTER parameters %q have %d fields, expected counter,description,unit,min,max", params, len(parts))
+	}
+
+	fields := make([]string, 5)
+	copy(fields, parts)
+	counterParams := checkNtCounterParams{
+		path:        fields[0],
+		description: fields[1],
+		unit:        fields[2],
+		min:         fields[3],
+		max:         fields[4],
+	}
+
+	for _, bound := range []string{counterParams.min, counterParams.max} {
+		if _, err := strconv.ParseFloat(bound, 64); bound != "" && err != nil {
+			return checkNtCounterParams{}, fmt.Errorf("COUNTER minimum or maximum %q is not a number", bound)
+		}
+	}
+	return counterParams, nil
+}
+
+// translateCheckNtThresholds converts check_nt -w and -c values into Nagios
+// ranges. check_nt alerts when a value is at or above the threshold unless
+// the warning threshold is greater than the critical one, in which case it
+// alerts when the value is at or below the threshold instead. UPTIME always
+// alerts when the value is at or below the threshold. Plain Nagios ranges
+// exclude their end, so the thresholds become inclusive ranges (i.e. 80 is
+// @80: and 10 is @~:10). Values which are not plain numbers are assumed to
+// already be Nagios ranges and are returned unchanged.
+func translateCheckNtThresholds(variable string, warning string, critical string) (string, string) {
+	warningValue, warningErr := strconv.ParseFloat(warning, 64)
+	criticalValue, criticalErr := strconv.ParseFloat(critical, 64)
+
+	lowerIsWorse := variable == checkNtVariableUptime || (warningErr == nil && criticalErr == nil && warningValue > criticalValue)
+
+	translate := func(threshold string, err error) string {
+		switch {
+		case err != nil:
+			return threshold
+		case lowerIsWorse:
+			return "@~:" + threshold
+		default:
+			return "@" + threshold + ":"
+		}
+	}
+
+	return translate(warning, warningErr), translate(critical, criticalErr)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNtThresholds(t *testing.T) {
+	t.Run("Thresholds are translated into inclusive ranges", func(t *testing.T) {
+		tests := []struct {
+			variable         string
+			warning          string
+			critical         string
+			expectedWarning  string
+			expectedCritical string
+		}{
+			{checkNtVariableCounter, "80", "90", "@80:", "@90:"},
+			{checkNtVariableCounter, "20", "10", "@~:20", "@~:10"},
+			{checkNtVariableCounter, "80", "", "@80:", ""},
+			{checkNtVariableMemUse, "80.5", "90", "@80.5:", "@90:"},
+			{checkNtVariableUptime, "10", "20", "@~:10", "@~:20"},
+			{checkNtVariableCounter, "10:", "5:", "10:", "5:"},
+		}
+
+		for _, test := range tests {
+			warning, critical := translateCheckNtThresholds(test.variable, test.warning, test.critical)
+			assert.Equal(t, test.expectedWarning, warning, test)
+			assert.Equal(t, test.expectedCritical, critical, test)
+		}
+	})
+
+	t.Run("Values at the threshold alert as they do with check_nt", func(t *testing.T) {
+		alerts := func(threshold string, value string) bool {
+			parsedRange := nagios.ParseRangeString(threshold)
+			assert.NotNil(t, parsedRange, threshold)
+			return parsedRange.CheckRange(value)
+		}
+
+		warning, _ := translateCheckNtThresholds(checkNtVariableCounter, "80", "90")
+		assert.True(t, alerts(warning, "80"))
+		assert.True(t, alerts(warning, "80.1"))
+		assert.False(t, alerts(warning, "79.9"))
+
+		warning, _ = translateCheckNtThresholds(checkNtVariableUptime, "10", "5")
+		assert.True(t, alerts(warning, "10"))
+		assert.True(t, alerts(warning, "9.9"))
+		assert.False(t, alerts(warning, "10.1"))
+	})
+}
+
+func TestCheckNtConnectionFlags(t *testing.T) {
+	t.Run("The NSClient port is ignored", func(t *testing.T) {
+		flags := flag.NewFlagSet("check_nt", flag.ContinueOnError)
+		agent := addConnectionFlags(flags, "")
+		addCheckNtConnectionFlags(flags, agent)
+
+		err := flags.Parse([]string{"-H", "server", "-p", "12489", "-s", "secret"})
+		assert.Nil(t, err)
+		assert.Equal(t, "server", *agent.hostname)
+		assert.Equal(t, "secret", *agent.password)
+		assert.Equal(t, 9000, *agent.port)
+	})
+
+	t.Run("The agent port is kept alongside the NSClient port", func(t *testing.T) {
+		flags := flag.NewFlagSet("check_nt", flag.ContinueOnError)
+		agent := addConnectionFlags(flags, "")
+		addCheckNtConnectionFlags(flags, agent)
+
+		err := flags.Parse([]string{"-port", "9443", "-p", "12489"})
+		assert.Nil(t, err)
+		assert.Equal(t, 9443, *agent.port)
+	})
+}
+
+func TestCheckNtCounterParams(t *testing.T) {
+	t.Run("Every field after the counter is optional", func(t *testing.T) {
+		tests := []struct {
+			params   string
+			expected checkNtCounterParams
+		}{
+			{
+				`\Memory\Available Bytes`,
+				checkNtCounterParams{path: `\Memory\Available Bytes`},
+			},
+			{
+				`"\Memory\Available Bytes","Available %.2f"`,
+				checkNtCounterParams{path: `\Memory\Available Bytes`, description: "Available %.2f"},
+			},
+			{
+				`"\Memory\Available Bytes","Available, free %.2f",B`,
+				checkNtCounterParams{path: `\Memory\Available Bytes`, description: "Available, free %.2f", unit: "B"},
+			},
+			{
+				`\Processor(_Total)\% Processor Time,CPU,%,0,100`,
+				checkNtCounterParams{path: `\Processor(_Total)\% Processor Time`, description: "CPU", unit: "%", min: "0", max: "100"},
+			},
+		}
+
+		for _, test := range tests {
+			counterParams, err := parseCheckNtCounterParams(test.params)
+			assert.Nil(t, err, test.params)
+			assert.Equal(t, test.expected, counterParams, test.params)
+		}
+	})
+
+	t.Run("Extra fields and bounds which are not numbers are errors", func(t *testing.T) {
+		for _, params := range []string{`\A\B,a,%,0,100,x`, `\A\B,a,%,zero`, `\A\B,a,%,0,max`} {
+			_, err := parseCheckNtCounterParams(params)
+			assert.NotNil(t, err, params)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/units"
+	"strconv"
+)
+
+// unitConversion converts the values of the counters, which are in from, to
+// the base unit of their dimension for the performance data and to a unit
+// people read easily for the output. A nil unitConversion leaves values as
+// they are.
+type unitConversion struct {
+	from units.Unit
+
+	// to is the unit of the output unless autoscale is set, and always the
+	// unit of the thresholds and of -format.
+	to        units.Unit
+	autoscale bool
+}
+
+// newUnitConversion returns the conversion from the -unit-from unit to the
+// -unit-to unit, or nil if no conversion was requested.
+func newUnitConversion(from string, to string, autoscale bool) (*unitConversion, error) {
+	if from == "" {
+		if to != "" || autoscale {
+			return nil, fmt.Errorf("unit-from is required to convert values")
+		}
+		return nil, nil
+	}
+
+	fromUnit, err := units.Lookup(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toUnit := fromUnit
+	if to != "" {
+		toUnit, err = units.Lookup(to)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := units.Convert(1, fromUnit, toUnit); err != nil {
+			return nil, err
+		}
+	}
+
+	return &unitConversion{from: fromUnit, to: toUnit, autoscale: autoscale}, nil
+}
+
+// perfDataUnit returns the unit of measurement of the performance data.
+func (c *unitConversion) perfDataUnit(unit string) string {
+	if c == nil {
+		return unit
+	}
+	return c.from.Dimension.PerfDataUnit
+}
+
+// perfDataValue converts a value to the base unit.
+func (c *unitConversion) perfDataValue(value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if c == nil || err != nil {
+		return value
+	}
+
+	base, _ := units.Convert(number, c.from, c.from.Base())
+	return strconv.FormatFloat(base, 'f', -1, 64)
+}
+
+// userValue converts a value to the unit of the thresholds and -format.
+func (c *unitConversion) userValue(value float64) float64 {
+	if c == nil {
+		return value
+	}
+
+	converted, _ := units.Convert(value, c.from, c.to)
+	return converted
+}
+
+// userUnit returns the unit of the values given to -format.
+func (c *unitConversion) userUnit(unit string) string {
+	if c == nil {
+		return unit
+	}
+	return c.to.Name
+}
+
+// format returns a value along with its unit for the output.
+func (c *unitConversion) format(value string, unit string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if c == nil || err != nil {
+		return value + unit
+	}
+
+	if c.autoscale {
+		return units.Format(units.Autoscale(number, c.from))
+	}
+	return units.Format(c.userValue(number), c.to)
+}
+
+// scaleRules converts threshold rules from the unit of the thresholds to the
+// base unit of the performance data.
+func (c *unitConversion) scaleRules(rules thresholdRules) (thresholdRules, error) {
+	if c == nil {
+		return rules, nil
+	}
+
+	factor, _ := units.Convert(1, c.to, c.from.Base())
+
+	scaled := make(thresholdRules, len(rules))
+	for i, rule := range rules {
+		threshold, err := scaleThreshold(rule.threshold, factor)
+		if err != nil {
+			return nil, err
+		}
+		scaled[i] = instanceThreshold{pattern: rule.pattern, threshold: threshold}
+	}
+	return scaled, nil
+}
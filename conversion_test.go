@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitConversion(t *testing.T) {
+	t.Run("Conversions need a unit to convert from", func(t *testing.T) {
+		conversion, err := newUnitConversion("", "", false)
+		assert.Nil(t, err)
+		assert.Nil(t, conversion)
+
+		_, err = newUnitConversion("", "GiB", false)
+		assert.EqualError(t, err, "unit-from is required to convert values")
+
+		_, err = newUnitConversion("", "", true)
+		assert.EqualError(t, err, "unit-from is required to convert values")
+
+		_, err = newUnitConversion("B", "ms", false)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Thresholds in the output unit are scaled to the base unit", func(t *testing.T) {
+		tests := []struct {
+			from     string
+			to       string
+			rules    string
+			expected []string
+		}{
+			{"B", "GiB", "1:", []string{"1073741824:"}},
+			{"B", "GiB", "C:=@~:1.1,*=2:", []string{"@~:1181116006.4", "2147483648:"}},
+			{"ticks", "ms", "@0.3:0.7", []string{"@0.0003:0.0007"}},
+			{"B", "KiB", "0.1:0.7", []string{"102.4:716.8"}},
+			{"ms", "ms", "1.3:4.1", []string{"0.0013:0.0041"}},
+			{"s", "min", "@4.1:", []string{"@246:"}},
+			{"B", "", "100", []string{"100"}},
+		}
+
+		for _, test := range tests {
+			conversion, err := newUnitConversion(test.from, test.to, false)
+			assert.Nil(t, err, test)
+			rules, err := parseThresholdRules(test.rules)
+			assert.Nil(t, err, test)
+
+			scaled, err := conversion.scaleRules(rules)
+			assert.Nil(t, err, test)
+			var thresholds []string
+			for _, rule := range scaled {
+				thresholds = append(thresholds, rule.threshold)
+			}
+			assert.Equal(t, test.expected, thresholds, test)
+		}
+	})
+
+	t.Run("Values are in the base unit for the performance data and the output unit for people", func(t *testing.T) {
+		conversion, err := newUnitConversion("KiB", "GiB", false)
+		assert.Nil(t, err)
+		assert.Equal(t, "B", conversion.perfDataUnit("%"))
+		assert.Equal(t, "2147483648", conversion.perfDataValue("2097152"))
+		assert.Equal(t, "n/a", conversion.perfDataValue("n/a"))
+		assert.Equal(t, 2.0, conversion.userValue(2097152))
+		assert.Equal(t, "2 GiB", conversion.format("2097152", "%"))
+
+		autoscaled, err := newUnitConversion("B", "", true)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.5 MiB", autoscaled.format("1572864", ""))
+
+		var none *unitConversion
+		assert.Equal(t, "%", none.perfDataUnit("%"))
+		assert.Equal(t, "5%", none.format("5", "%"))
+	})
+
+	t.Run("Counters are checked against thresholds in the output unit", func(t *testing.T) {
+		connection := newMockConnection(map[string][]CounterResultItem{
+			`\Memory\Available Bytes`: {{Value: "1610612736"}},
+		})
+		checks, err := newCounterChecks(newCounterFlags(`\Memory\Available Bytes`), newCounterFlags("available"), newCounterFlags(), defaultCounterUnit, newCounterFlags("2:"), newCounterFlags("1:"))
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		options := testCounterOptions()
+		options.conversion, err = newUnitConversion("B", "GiB", false)
+		assert.Nil(t, err)
+		for i := range checks {
+			checks[i].warning, _ = options.conversion.scaleRules(checks[i].warning)
+			checks[i].critical, _ = options.conversion.scaleRules(checks[i].critical)
+		}
+
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, options)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateWARNINGExitCode, exitCode)
+		assert.Contains(t, output, "available: 1.5 GiB")
+		assert.Contains(t, output, "'available'=1610612736B;2147483648:;1073741824:;;")
+	})
+}
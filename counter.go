@@ -0,0 +1,455 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCounterUnit = "%"
+
+// defaultRateUnit is the unit of rates unless another is given, as a
+// percentage rarely makes sense for a count per second.
+const defaultRateUnit = ""
+
+// counterCheck is a single counter path to check, along with the label,
+// unit and thresholds which apply to it.
+type counterCheck struct {
+	name     string
+	path     string
+	label    string
+	unit     string
+	warning  thresholdRules
+	critical thresholdRules
+
+	// counter is the parsed path, which is left empty for the result of an
+	// expression.
+	counter counterpath.CounterPath
+}
+
+// counterOptions are the settings which apply to every counter check.
+type counterOptions struct {
+
+	// description is a printf style template formatted with each value to
+	// build the service output.
+	description string
+
+	filter instanceFilter
+
+	// perfDataMin and perfDataMax are the bounds of the counter values in
+	// the performance data, if known.
+	perfDataMin string
+	perfDataMax string
+
+	// emptyState is the state used when a counter returns no values, or
+	// the filter leaves no instances of it to check.
+	emptyState nagios.ServiceState
+
+	// nanState and undeterminedState are the states used when a counter
+	// returns a value which is not numeric or is U.
+	nanState          nagios.ServiceState
+	undeterminedState nagios.ServiceState
+
+	// aggregate is the name of the function reducing the instances of each
+	// counter to a single value, if any.
+	aggregate string
+
+	// aggregatePerfData also emits the performance data of the instances
+	// which were aggregated.
+	aggregatePerfData bool
+
+	// rate evaluates the per second rate of cumulative counters, using the
+	// values recorded in stateDirectory by the previous check.
+	rate           bool
+	stateDirectory string
+
+	// counterMax is the value at which the counters wrap around to 0, or 0
+	// if unknown.
+	counterMax float64
+
+	// firstRunState is the state used while there is no previous value to
+	// calculate a rate from.
+	firstRunState nagios.ServiceState
+
+	// samples is the number of times the counters are queried, interval
+	// apart, with statistic being the statistic of the samples the
+	// thresholds apply to. No sample is taken which would end after
+	// sampleDeadline, if set.
+	samples        int
+	interval       time.Duration
+	statistic      string
+	sampleDeadline time.Time
+
+	// top is the number of instances with the highest values listed in the
+	// long output, topPerfData limiting the performance data to them.
+	top         int
+	topPerfData bool
+
+	// conversion converts the values from the unit of the counters, if set.
+	conversion *unitConversion
+
+	// expression replaces the results of the counters with an expression
+	// evaluated over them, in which case there is a single check for the
+	// result of the expression.
+	expression *counterExpression
+}
+
+// newCounterChecks groups the repeatable counter flags by their position, so
+// the second -label, -unit, -warning and -critical apply to the second
+// -counter. A flag given only once applies to every counter.
+func newCounterChecks(paths stringListFlag, labels stringListFlag, units stringListFlag, defaultUnit string, warnings stringListFlag, criticals stringListFlag) ([]counterCheck, error) {
+	if len(paths.values) == 0 {
+		return nil, fmt.Errorf("counter is not set")
+	}
+
+	valueAt := func(name string, values stringListFlag, index int, defaultValue string) (string, error) {
+		switch len(values.values) {
+		case 0:
+			return defaultValue, nil
+		case 1:
+			return values.values[0], nil
+		case len(paths.values):
+			return values.values[index], nil
+		default:
+			return "", fmt.Errorf("%s was given %d times for %d counters", name, len(values.values), len(paths.values))
+		}
+	}
+
+	checks := make([]counterCheck, len(paths.values))
+	for i, path := range paths.values {
+		var err error
+		checks[i].name, checks[i].path = splitCounterName(path)
+		if checks[i].label, err = valueAt("label", labels, i, ""); err != nil {
+			return nil, err
+		}
+		if checks[i].unit, err = valueAt("unit", units, i, defaultUnit); err != nil {
+			return nil, err
+		}
+
+		warning, err := valueAt("warning", warnings, i, "")
+		if err != nil {
+			return nil, err
+		}
+		if checks[i].warning, err = parseThresholdRules(warning); err != nil {
+			return nil, err
+		}
+
+		critical, err := valueAt("critical", criticals, i, "")
+		if err != nil {
+			return nil, err
+		}
+		if checks[i].critical, err = parseThresholdRules(critical); err != nil {
+			return nil, err
+		}
+	}
+
+	return checks, nil
+}
+
+// parseCounterPaths validates the path of every check before any request
+// is made, so that typos are reported precisely rather than by the agent.
+// The paths are replaced with the form passed to Windows, without escaping.
+func parseCounterPaths(checks []counterCheck) error {
+	for i := range checks {
+		counter, err := counterpath.Parse(checks[i].path)
+		if err != nil {
+			return err
+		}
+		checks[i].counter = counter
+		checks[i].path = counter.String()
+	}
+	return nil
+}
+
+// queryCounters requests every counter at the same time, as the agent only
+// accepts a single counter path per request.
+func queryCounters(connection agentConnection, checks []counterCheck) ([]CounterResult, error) {
+	results := make([]CounterResult, len(checks))
+	errors := make([]error, len(checks))
+
+	var wait sync.WaitGroup
+	for i := range checks {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			results[i], errors[i] = connection.queryCounter(checks[i].path)
+		}(i)
+	}
+	wait.Wait()
+
+	for _, err := range errors {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// counterResults returns the results of each check, which is the result of
+// the expression when there is one, along with the number of samples taken.
+// Instances the expression cannot be evaluated for are reported as errors.
+func counterResults(plugin *nagios.Plugin, connection agentConnection, checks []counterCheck, options counterOptions) ([]CounterResult, int, error) {
+	reported := map[string]bool{}
+
+	query := func() ([]CounterResult, error) {
+		if options.expression == nil {
+			return queryCounters(connection, checks)
+		}
+
+		result, instanceErrors, err := options.expression.evaluate(connection)
+		if err != nil {
+			return nil, err
+		}
+
+		// Each sample evaluates the expression again, the same error is
+		// only reported once.
+		for _, instanceError := range instanceErrors {
+			if !reported[instanceError.Error()] {
+				reported[instanceError.Error()] = true
+				plugin.RaiseExitStatusCode(options.errorState(instanceError))
+				plugin.AddError(instanceError)
+			}
+		}
+
+		return []CounterResult{result}, nil
+	}
+
+	if options.samples > 1 {
+		return sampleCounters(query, options.samples, options.interval, options.statistic, options.sampleDeadline)
+	}
+	results, err := query()
+	return results, 1, err
+}
+
+// errorState returns the exit code for an instance which could not be
+// checked because of err.
+func (options counterOptions) errorState(err error) int {
+	switch {
+	case errors.Is(err, nagios.ErrPerformanceDataUndetermined):
+		return options.undeterminedState.ExitCode
+	case errors.Is(err, nagios.ErrPerformanceDataNotNumeric):
+		return options.nanState.ExitCode
+	default:
+		return nagios.StateUNKNOWNExitCode
+	}
+}
+
+// checkValues reports the instances with values which cannot be checked,
+// because they are U or not numeric, and returns the other instances.
+func checkValues(plugin *nagios.Plugin, check counterCheck, instances []CounterResultItem, options counterOptions) []CounterResultItem {
+	var checkable []CounterResultItem
+	for _, instance := range instances {
+		if _, err := nagios.ParsePerfDataValue(instance.Value); err != nil {
+			plugin.RaiseExitStatusCode(options.errorState(err))
+			plugin.AddError(fmt.Errorf("%s of %s: %w", describeInstance(instance.InstanceName), check.path, err))
+			continue
+		}
+		checkable = append(checkable, instance)
+	}
+	return checkable
+}
+
+// checkCounters evaluates every instance returned for each of the counters
+// against the thresholds of that counter, the plugin ends up in the worst
+// state found.
+func checkCounters(plugin *nagios.Plugin, connection agentConnection, checks []counterCheck, options counterOptions) error {
+	description := options.description
+	if description != "" {
+		if _, err := formatDescription(description, 0, ""); err != nil {
+			return err
+		}
+	}
+
+	// Labels are built from English names, even when the counters were
+	// given by their index.
+	for i := range checks {
+		checks[i].counter = connection.counterMap.English(checks[i].counter)
+	}
+
+	results, samples, err := counterResults(plugin, connection, checks, options)
+	if err != nil {
+		return err
+	}
+
+	var descriptions []string
+	var details []string
+
+	if samples < options.samples {
+		details = append(details, fmt.Sprintf("only %d of %d samples were taken within the timeout", samples, options.samples))
+	}
+
+	for i, check := range checks {
+		instances := options.filter.apply(results[i].Results)
+
+		// A count of no instances is a value of its own.
+		if len(instances) == 0 && options.aggregate != aggregateCount {
+			plugin.RaiseExitStatusCode(options.emptyState.ExitCode)
+			if len(results[i].Results) > 0 {
+				plugin.AddError(fmt.Errorf("no instances of %s are left after filtering", check.path))
+			} else {
+				plugin.AddError(fmt.Errorf("no values were returned for %s", check.path))
+			}
+			continue
+		}
+
+		instances = checkValues(plugin, check, instances, options)
+		if len(instances) == 0 && options.aggregate != aggregateCount {
+			continue
+		}
+
+		if options.rate {
+			var pending []string
+			statePath := rateStateFilePath(options.stateDirectory, connection, i, check)
+			instances, pending, err = counterRates(statePath, check.path, instances, options.counterMax, time.Now())
+			if err != nil {
+				return err
+			}
+			details = append(details, pending...)
+
+			if len(instances) == 0 && len(pending) > 0 {
+				plugin.RaiseExitStatusCode(options.firstRunState.ExitCode)
+				descriptions = append(descriptions, fmt.Sprintf("no previous value of %s to calculate a rate from, rates are available from the next check", check.path))
+				continue
+			}
+		}
+
+		unit := check.unit
+		conversion := options.conversion
+		perfDataMin := options.perfDataMin
+		perfDataMax := options.perfDataMax
+
+		// perfDataInstances limits the performance data to the top
+		// instances, when set.
+		var perfDataInstances map[string]bool
+		if options.top > 0 {
+			top := topInstances(instances, options.top)
+			details = append(details, topReport(check, top, len(instances), unit, conversion)...)
+			if options.topPerfData {
+				perfDataInstances = topNames(top)
+			}
+		}
+
+		if options.aggregate != "" && (len(instances) > 0 || options.aggregate == aggregateCount) {
+			if options.aggregatePerfData {
+				for _, outputValue := range instances {
+					if perfDataInstances != nil && !perfDataInstances[strings.ToLower(outputValue.InstanceName)] {
+						continue
+					}
+					err := plugin.AddPerfData(false, nagios.PerformanceData{
+						Label:             outputValue.InstanceName,
+						Value:             conversion.perfDataValue(outputValue.Value),
+						UnitOfMeasurement: conversion.perfDataUnit(check.unit),
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			aggregated, err := aggregateInstances(options.aggregate, instances, check.path)
+			if err != nil {
+				return err
+			}
+			instances = []CounterResultItem{aggregated}
+			perfDataInstances = nil
+
+			// A count is a number of instances, whatever the counter measures.
+			if options.aggregate == aggregateCount {
+				unit = ""
+				conversion = nil
+				perfDataMin = ""
+				perfDataMax = ""
+			}
+		}
+
+		for index, outputValue := range instances {
+
+			thisCounterLabel := outputValue.InstanceName
+
+			// Counters without instances, such as \Memory\Committed Bytes,
+			// are labelled after the counter.
+			if thisCounterLabel == "" {
+				thisCounterLabel = check.counterName()
+			}
+
+			if check.label != "" {
+				thisCounterLabel = expandLabel(check.label, check, outputValue.InstanceName, index)
+			}
+
+			warning, warningPattern := check.warning.lookup(outputValue.InstanceName)
+			critical, criticalPattern := check.critical.lookup(outputValue.InstanceName)
+
+			perfdata := nagios.PerformanceData{
+				Label:             thisCounterLabel,
+				Value:             conversion.perfDataValue(outputValue.Value),
+				UnitOfMeasurement: conversion.perfDataUnit(unit),
+				Warn:              warning,
+				Crit:              critical,
+				Min:               conversion.perfDataValue(perfDataMin),
+				Max:               conversion.perfDataValue(perfDataMax),
+			}
+			plugin.EvaluateThreshold(perfdata)
+
+			if perfDataInstances == nil || perfDataInstances[strings.ToLower(outputValue.InstanceName)] {
+				if err := plugin.AddPerfData(false, perfdata); err != nil {
+					return err
+				}
+
+				for _, statistic := range outputValue.statistics {
+					err := plugin.AddPerfData(false, nagios.PerformanceData{
+						Label:             thisCounterLabel + "_" + statistic.name,
+						Value:             conversion.perfDataValue(statistic.value),
+						UnitOfMeasurement: conversion.perfDataUnit(unit),
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			if check.warning.keyed() || check.critical.keyed() {
+				plugin.AddThresholdDetail(fmt.Sprintf(
+					"%s: %s %s (%s), %s %s (%s)",
+					outputValue.InstanceName,
+					nagios.StateCRITICALLabel,
+					describeThreshold(critical),
+					describeThreshold(criticalPattern),
+					nagios.StateWARNINGLabel,
+					describeThreshold(warning),
+					describeThreshold(warningPattern),
+				))
+				details = append(details, fmt.Sprintf("%s: %s", outputValue.InstanceName, conversion.format(outputValue.Value, unit)))
+			}
+
+			if description != "" {
+				value, err := strconv.ParseFloat(outputValue.Value, 64)
+				if err != nil {
+					return fmt.Errorf("value %q returned for %s is not numeric", outputValue.Value, check.path)
+				}
+				formatted, _ := formatDescription(description, conversion.userValue(value), conversion.userUnit(unit))
+				descriptions = append(descriptions, formatted)
+			} else if conversion != nil {
+				descriptions = append(descriptions, fmt.Sprintf("%s: %s", thisCounterLabel, conversion.format(outputValue.Value, unit)))
+			}
+		}
+	}
+
+	plugin.LongServiceOutput = strings.Join(details, nagios.CheckOutputEOL)
+	setServiceOutput(plugin, strings.Join(descriptions, ", "))
+	return nil
+}
+
+// describeThreshold returns a threshold or pattern for display, as either
+// may be empty when no threshold applies to an instance.
+func describeThreshold(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
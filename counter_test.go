@@ -0,0 +1,204 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newCounterFlags returns a list flag holding values.
+func newCounterFlags(values ...string) stringListFlag {
+	return stringListFlag{values: values}
+}
+
+// testCounterOptions are the counter options main uses by default.
+func testCounterOptions() counterOptions {
+	unknown, _ := nagios.ParseServiceState(nagios.StateUNKNOWNLabel)
+	ok, _ := nagios.ParseServiceState(nagios.StateOKLabel)
+	return counterOptions{
+		emptyState:        unknown,
+		nanState:          unknown,
+		undeterminedState: unknown,
+		firstRunState:     ok,
+		samples:           1,
+		statistic:         defaultStatistic,
+	}
+}
+
+func TestNewCounterChecks(t *testing.T) {
+	paths := newCounterFlags(`\A\X`, `\B\Y`, `\C\Z`)
+
+	t.Run("Values given once apply to every counter", func(t *testing.T) {
+		checks, err := newCounterChecks(paths, newCounterFlags("label"), newCounterFlags(), defaultCounterUnit, newCounterFlags("80"), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Len(t, checks, 3)
+		for _, check := range checks {
+			assert.Equal(t, "label", check.label)
+			assert.Equal(t, defaultCounterUnit, check.unit)
+			assert.Equal(t, thresholdRules{{pattern: defaultThresholdPattern, threshold: "80"}}, check.warning)
+			assert.Empty(t, check.critical)
+		}
+	})
+
+	t.Run("Values given for each counter apply by position", func(t *testing.T) {
+		checks, err := newCounterChecks(paths, newCounterFlags("a", "b", "c"), newCounterFlags("", "B", "s"), defaultCounterUnit, newCounterFlags(), newCounterFlags("1", "2", "3"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, []string{checks[0].label, checks[1].label, checks[2].label})
+		assert.Equal(t, []string{"", "B", "s"}, []string{checks[0].unit, checks[1].unit, checks[2].unit})
+		assert.Equal(t, "3", checks[2].critical[0].threshold)
+		assert.Equal(t, `\C\Z`, checks[2].path)
+	})
+
+	t.Run("Any other number of values is an error", func(t *testing.T) {
+		_, err := newCounterChecks(paths, newCounterFlags("a", "b"), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.EqualError(t, err, "label was given 2 times for 3 counters")
+
+		_, err = newCounterChecks(paths, newCounterFlags(), newCounterFlags(), defaultCounterUnit, newCounterFlags("1", "2", "3", "4"), newCounterFlags())
+		assert.EqualError(t, err, "warning was given 4 times for 3 counters")
+	})
+
+	t.Run("A counter is required", func(t *testing.T) {
+		_, err := newCounterChecks(newCounterFlags(), newCounterFlags(), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.EqualError(t, err, "counter is not set")
+	})
+
+	t.Run("Named counters are split from their path", func(t *testing.T) {
+		checks, err := newCounterChecks(newCounterFlags(`free=\Memory\Available Bytes`), newCounterFlags(), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Equal(t, "free", checks[0].name)
+		assert.Equal(t, `\Memory\Available Bytes`, checks[0].path)
+	})
+}
+
+func TestCheckCounters(t *testing.T) {
+	connection := newMockConnection(map[string][]CounterResultItem{
+		`\PhysicalDisk(_Total)\Avg. Disk Queue Length`: {{InstanceName: "_Total", Value: "1"}},
+		`\Processor(_Total)\% Processor Time`:          {{InstanceName: "_Total", Value: "85"}},
+		`\LogicalDisk(*)\% Free Space`: {
+			{InstanceName: "C:", Value: "4"},
+			{InstanceName: "D:", Value: "50"},
+		},
+	})
+
+	check := func(t *testing.T, paths []string, labels []string, warnings []string, criticals []string) (string, int) {
+		checks, err := newCounterChecks(newCounterFlags(paths...), newCounterFlags(labels...), newCounterFlags(""), "", newCounterFlags(warnings...), newCounterFlags(criticals...))
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, testCounterOptions())
+		})
+		assert.Nil(t, err)
+		return output, exitCode
+	}
+
+	t.Run("The worst state across the counters is the result", func(t *testing.T) {
+		paths := []string{`\PhysicalDisk(_Total)\Avg. Disk Queue Length`, `\Processor(_Total)\% Processor Time`}
+		labels := []string{"queue", "cpu"}
+
+		_, exitCode := check(t, paths, labels, []string{"2", "90"}, []string{"5", "95"})
+		assert.Equal(t, nagios.StateOKExitCode, exitCode)
+
+		_, exitCode = check(t, paths, labels, []string{"2", "80"}, []string{"5", "95"})
+		assert.Equal(t, nagios.StateWARNINGExitCode, exitCode)
+
+		output, exitCode := check(t, paths, labels, []string{"0.5", "80"}, []string{"0.8", "95"})
+		assert.Equal(t, nagios.StateCRITICALExitCode, exitCode)
+		assert.Contains(t, output, "'cpu'=85;80;95;; 'queue'=1;0.5;0.8;;")
+	})
+
+	t.Run("Every instance is checked", func(t *testing.T) {
+		output, exitCode := check(t, []string{`\LogicalDisk(*)\% Free Space`}, nil, []string{"10:"}, []string{"5:"})
+		assert.Equal(t, nagios.StateCRITICALExitCode, exitCode)
+		assert.Contains(t, output, "'C:'=4;10:;5:;;")
+		assert.Contains(t, output, "'D:'=50;10:;5:;;")
+	})
+
+	t.Run("The bounds are added to the performance data", func(t *testing.T) {
+		checks, err := newCounterChecks(newCounterFlags(`\Processor(_Total)\% Processor Time`), newCounterFlags("cpu"), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		options := testCounterOptions()
+		options.perfDataMin = "0"
+		options.perfDataMax = "100"
+		output, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, options)
+		})
+		assert.Nil(t, err)
+		assert.Contains(t, output, "'cpu'=85%;;;0;100")
+	})
+
+	t.Run("Empty, non-numeric and undetermined values are reported in their states", func(t *testing.T) {
+		connection := newMockConnection(map[string][]CounterResultItem{
+			`\Test\Empty`:        {},
+			`\Test\Text`:         {{Value: "n/a"}},
+			`\Test\Undetermined`: {{Value: "U"}},
+			`\Test\Number`:       {{Value: "1"}},
+		})
+		warning, _ := nagios.ParseServiceState(nagios.StateWARNINGLabel)
+		critical, _ := nagios.ParseServiceState(nagios.StateCRITICALLabel)
+		ok, _ := nagios.ParseServiceState(nagios.StateOKLabel)
+
+		tests := []struct {
+			path             string
+			options          func(options *counterOptions)
+			expectedExitCode int
+			expectedError    string
+		}{
+			{`\Test\Empty`, func(options *counterOptions) { options.emptyState = critical }, nagios.StateCRITICALExitCode, `no values were returned for \Test\Empty`},
+			{`\Test\Empty`, func(options *counterOptions) { options.emptyState = ok }, nagios.StateOKExitCode, `no values were returned for \Test\Empty`},
+			{`\Test\Text`, func(options *counterOptions) { options.nanState = warning }, nagios.StateWARNINGExitCode, `the counters of \Test\Text: performance data value is not numeric`},
+			{`\Test\Undetermined`, func(options *counterOptions) { options.undeterminedState = critical }, nagios.StateCRITICALExitCode, `the counters of \Test\Undetermined: performance data value is undetermined`},
+		}
+
+		for _, test := range tests {
+			checks, err := newCounterChecks(newCounterFlags(test.path, `\Test\Number`), newCounterFlags("value", "number"), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+			assert.Nil(t, err)
+			assert.Nil(t, parseCounterPaths(checks))
+
+			options := testCounterOptions()
+			test.options(&options)
+			output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+				return checkCounters(plugin, connection, checks, options)
+			})
+			assert.Nil(t, err, test.path)
+			assert.Equal(t, test.expectedExitCode, exitCode, test.path)
+			assert.Contains(t, output, "ERRORS", test.path)
+			assert.Contains(t, output, test.expectedError, test.path)
+			assert.Contains(t, output, "'number'=1%", test.path)
+			assert.NotContains(t, output, "'value'=", test.path)
+		}
+	})
+
+	t.Run("Labels colliding across instances fail with the error policy", func(t *testing.T) {
+		checks, err := newCounterChecks(newCounterFlags(`\LogicalDisk(*)\% Free Space`), newCounterFlags("free"), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		_, _, err = runCheck(func(plugin *nagios.Plugin) error {
+			plugin.SetPerfDataCollisionPolicy(nagios.PerfDataCollisionError)
+			return checkCounters(plugin, connection, checks, testCounterOptions())
+		})
+		assert.ErrorIs(t, err, nagios.ErrPerformanceDataCollision)
+
+		output, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			plugin.SetPerfDataCollisionPolicy(nagios.PerfDataCollisionSuffix)
+			return checkCounters(plugin, connection, checks, testCounterOptions())
+		})
+		assert.Nil(t, err)
+		assert.Contains(t, output, "'free'=4%")
+		assert.Contains(t, output, "'free_2'=50%")
+	})
+
+	t.Run("Failing requests are returned as errors", func(t *testing.T) {
+		checks, _ := newCounterChecks(newCounterFlags(`\Missing\Counter`), newCounterFlags(), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.Nil(t, parseCounterPaths(checks))
+		_, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, testCounterOptions())
+		})
+		assert.Contains(t, err.Error(), "Response code: 404")
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const cpuLoadCounterPath = `\Processor(_Total)\% Processor Time`
+
+// cpuLoadHistoryRetention is how long samples are kept for when no longer
+// window has been requested, it matches the default CPU buffer of NSClient.
+const cpuLoadHistoryRetention = time.Hour
+
+// cpuLoadWindow is a single averaging window of a CPULOAD check along with
+// the thresholds for it.
+type cpuLoadWindow struct {
+	minutes  int
+	warning  string
+	critical string
+}
+
+// cpuLoadSample is a single reading of the processor time counter as
+// recorded in the history file.
+type cpuLoadSample struct {
+	Time  time.Time
+	Value float64
+}
+
+// parseCPULoadParams parses the check_nt CPULOAD parameters, a list of
+// minutes,warning,critical triplets (i.e. 5,80,90,10,80,90). The thresholds
+// follow the check_nt rules of -w and -c.
+func parseCPULoadParams(params string) ([]cpuLoadWindow, error) {
+	parts := splitCheckNtParams(params)
+	if params == "" || len(parts)%3 != 0 {
+		return nil, fmt.Errorf("CPULOAD requires parameters in the form minutes,warning,critical[,minutes,warning,critical...] but got %q", params)
+	}
+
+	var windows []cpuLoadWindow
+	for i := 0; i < len(parts); i += 3 {
+		minutes, err := strconv.Atoi(parts[i])
+		if err != nil || minutes <= 0 {
+			return nil, fmt.Errorf("CPULOAD window %q is not a positive number of minutes", parts[i])
+		}
+		warning, critical := translateCheckNtThresholds(checkNtVariableCPULoad, parts[i+1], parts[i+2])
+		for _, threshold := range []string{warning, critical} {
+			if threshold != "" && nagios.ParseRangeString(threshold) == nil {
+				return nil, fmt.Errorf("CPULOAD window %d has an invalid threshold %q", minutes, threshold)
+			}
+		}
+		windows = append(windows, cpuLoadWindow{
+			minutes:  minutes,
+			warning:  warning,
+			critical: critical,
+		})
+	}
+
+	return windows, nil
+}
+
+// cpuLoadAverages takes a sample of the processor time counter, records it
+// in the history file and returns the average of the samples within each of
+// the requested windows. The agent only reports the current load, so the
+// averages are built from the samples taken by previous checks.
+func cpuLoadAverages(connection agentConnection, historyFilePath string, minutes []int) ([]float64, error) {
+	value, err := connection.queryCounterValue(cpuLoadCounterPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []cpuLoadSample
+	err = loadState(historyFilePath, &history)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CPU load history %s", err.Error())
+	}
+
+	now := time.Now()
+	history = append(history, cpuLoadSample{Time: now, Value: value})
+
+	retention := cpuLoadHistoryRetention
+	for _, windowMinutes := range minutes {
+		if window := time.Duration(windowMinutes) * time.Minute; window > retention {
+			retention = window
+		}
+	}
+
+	var retained []cpuLoadSample
+	for _, sample := range history {
+		if now.Sub(sample.Time) <= retention {
+			retained = append(retained, sample)
+		}
+	}
+
+	err = saveState(historyFilePath, retained)
+	if err != nil {
+		return nil, fmt.Errorf("error writing CPU load history %s", err.Error())
+	}
+
+	averages := make([]float64, len(minutes))
+	for i, windowMinutes := range minutes {
+		window := time.Duration(windowMinutes) * time.Minute
+		total := 0.0
+		count := 0
+		for _, sample := range retained {
+			if now.Sub(sample.Time) <= window {
+				total += sample.Value
+				count++
+			}
+		}
+		averages[i] = total / float64(count)
+	}
+
+	return averages, nil
+}
+
+// checkCPULoad emulates the check_nt CPULOAD variable.
+func checkCPULoad(plugin *nagios.Plugin, connection agentConnection, stateDirectory string, params string) error {
+	windows, err := parseCPULoadParams(params)
+	if err != nil {
+		return err
+	}
+
+	minutes := make([]int, len(windows))
+	for i, window := range windows {
+		minutes[i] = window.minutes
+	}
+
+	averages, err := cpuLoadAverages(connection, stateFilePath(stateDirectory, "cpuload", connection.url), minutes)
+	if err != nil {
+		return err
+	}
+
+	var summary []string
+	for i, window := range windows {
+		perfdata := nagios.PerformanceData{
+			Label:             fmt.Sprintf("%d min avg Load", window.minutes),
+			Value:             strconv.FormatFloat(averages[i], 'f', 2, 64),
+			UnitOfMeasurement: "%",
+			Warn:              window.warning,
+			Crit:              window.critical,
+			Min:               "0",
+			Max:               "100",
+		}
+		plugin.AddPerfData(false, perfdata)
+		plugin.EvaluateThreshold(perfdata)
+
+		summary = append(summary, fmt.Sprintf("%.0f%% (%d min average)", averages[i], window.minutes))
+	}
+
+	setServiceOutput(plugin, "CPU Load "+strings.Join(summary, " "))
+	return nil
+}
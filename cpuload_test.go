@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCPULoadParams(t *testing.T) {
+	t.Run("Triplets are parsed into windows", func(t *testing.T) {
+		windows, err := parseCPULoadParams("5,80,90,10,70,95")
+		assert.Nil(t, err)
+		assert.Equal(t, []cpuLoadWindow{
+			{minutes: 5, warning: "@80:", critical: "@90:"},
+			{minutes: 10, warning: "@70:", critical: "@95:"},
+		}, windows)
+	})
+
+	t.Run("Invalid parameters are reported", func(t *testing.T) {
+		for _, params := range []string{"", "5,80", "0,80,90", "x,80,90", "5,abc,90", "5,80,9x"} {
+			_, err := parseCPULoadParams(params)
+			assert.NotNil(t, err, params)
+		}
+	})
+}
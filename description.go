@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// descriptionFlags are the printf flags which may appear in a description.
+const descriptionFlags = "+- #0"
+
+// formatDescription renders a check_nt style description (i.e. "Queue length
+// is %.2f") for a counter value. Every verb is given the same value. The
+// integer verbs d, i and u round the value, the floating point verbs f, F, e,
+// E, g and G use it as is and %% is a literal percent sign. Any other verb is
+// an error, so that a mistake in a template is reported instead of producing
+// garbled output. Like check_nt, a template without any verb for the value
+// is followed by " = ", the value with two decimals and the unit.
+func formatDescription(template string, value float64, unit string) (string, error) {
+	var output strings.Builder
+	formatted := false
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			output.WriteByte(template[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(template) && strings.IndexByte(descriptionFlags, template[i]) >= 0 {
+			i++
+		}
+		for i < len(template) && template[i] >= '0' && template[i] <= '9' {
+			i++
+		}
+		if i < len(template) && template[i] == '.' {
+			i++
+			for i < len(template) && template[i] >= '0' && template[i] <= '9' {
+				i++
+			}
+		}
+		if i >= len(template) {
+			return "", fmt.Errorf("description %q ends with an incomplete format verb", template)
+		}
+
+		specification := template[start:i]
+		switch template[i] {
+		case '%':
+			if specification != "%" {
+				return "", fmt.Errorf("description %q has an invalid %%%% verb", template)
+			}
+			output.WriteByte('%')
+		case 'd', 'i', 'u':
+			output.WriteString(fmt.Sprintf(specification+"d", int64(math.Round(value))))
+			formatted = true
+		case 'f', 'F':
+			output.WriteString(fmt.Sprintf(specification+"f", value))
+			formatted = true
+		case 'e', 'E', 'g', 'G':
+			output.WriteString(fmt.Sprintf(specification+string(template[i]), value))
+			formatted = true
+		default:
+			return "", fmt.Errorf("description %q has an unsupported format verb %s", template, template[start:i+1])
+		}
+	}
+
+	if !formatted {
+		fmt.Fprintf(&output, " = %.2f", value)
+		if unit != "" {
+			output.WriteString(" " + unit)
+		}
+	}
+
+	return output.String(), nil
+}
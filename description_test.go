@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDescription(t *testing.T) {
+	t.Run("Values are formatted by every verb", func(t *testing.T) {
+		tests := []struct {
+			template string
+			value    float64
+			unit     string
+			expected string
+		}{
+			{"Queue length is %.2f", 1.234, "", "Queue length is 1.23"},
+			{"%d processes", 41.6, "", "42 processes"},
+			{"%5.1f%%", 12.34, "%", " 12.3%"},
+			{"Available", 1024, "B", "Available = 1024.00 B"},
+			{"Uptime", 3, "", "Uptime = 3.00"},
+			{"Load is 100%%", 5, "%", "Load is 100% = 5.00 %"},
+			{"%d of %.1f (%g)", 2.5, "", "3 of 2.5 (2.5)"},
+			{"%+e", 1500, "", "+1.500000e+03"},
+		}
+
+		for _, test := range tests {
+			formatted, err := formatDescription(test.template, test.value, test.unit)
+			assert.Nil(t, err, test.template)
+			assert.Equal(t, test.expected, formatted, test.template)
+		}
+	})
+
+	t.Run("Incomplete and unsupported verbs are errors", func(t *testing.T) {
+		for template, message := range map[string]string{
+			"Usage is 50%":   "ends with an incomplete format verb",
+			"Usage is %.2":   "ends with an incomplete format verb",
+			"Name is %s":     "unsupported format verb %s",
+			"Value is %x":    "unsupported format verb %x",
+			"Percent is %5%": "invalid %% verb",
+		} {
+			_, err := formatDescription(template, 1, "")
+			if assert.NotNil(t, err, template) {
+				assert.Contains(t, err.Error(), message, template)
+			}
+		}
+	})
+}
+
+func TestCounterDescriptions(t *testing.T) {
+	connection := newMockConnection(map[string][]CounterResultItem{
+		`\PhysicalDisk(_Total)\Avg. Disk Queue Length`: {{InstanceName: "_Total", Value: "1.5"}},
+	})
+
+	check := func(t *testing.T, description string) (string, error) {
+		checks, err := newCounterChecks(newCounterFlags(`\PhysicalDisk(_Total)\Avg. Disk Queue Length`), newCounterFlags("queue"), newCounterFlags(""), defaultCounterUnit, newCounterFlags("2"), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		options := testCounterOptions()
+		options.description = description
+		output, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, options)
+		})
+		return output, err
+	}
+
+	t.Run("The description is the output and the label stays the performance data label", func(t *testing.T) {
+		output, err := check(t, "Queue length is %.2f")
+		assert.Nil(t, err)
+		assert.Contains(t, output, "OK: Queue length is 1.50")
+		assert.Contains(t, output, "'queue'=1.5;2;;;")
+	})
+
+	t.Run("A description without a verb is followed by the value", func(t *testing.T) {
+		output, err := check(t, "Queue length")
+		assert.Nil(t, err)
+		assert.Contains(t, output, "OK: Queue length = 1.50")
+	})
+
+	t.Run("An invalid description is reported before querying", func(t *testing.T) {
+		_, err := check(t, "Queue length is %s")
+		assert.Contains(t, err.Error(), "unsupported format verb")
+	})
+}
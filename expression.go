@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/expr"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultExpressionUnit is the unit of expression results unless another is
+// given, as the unit of the counters rarely carries over.
+const defaultExpressionUnit = ""
+
+// counterNamePattern matches the name bound to a counter with -counter
+// name=\Object\Counter.
+var counterNamePattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(\\.*)$`)
+
+// splitCounterName returns the name bound to a counter, if any, and its
+// path.
+func splitCounterName(value string) (name string, path string) {
+	if match := counterNamePattern.FindStringSubmatch(value); match != nil {
+		return match[1], match[2]
+	}
+	return "", value
+}
+
+// counterExpression is an -expr evaluated over named counters, the result
+// of which is checked in place of the counters themselves.
+type counterExpression struct {
+	expression *expr.Expression
+	inputs     []counterCheck
+}
+
+// newCounterExpression parses an expression and checks that every variable
+// it uses is bound to one of the counters.
+func newCounterExpression(source string, inputs []counterCheck) (*counterExpression, error) {
+	expression, err := expr.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, input := range inputs {
+		if input.name == "" {
+			return nil, fmt.Errorf("counter %s needs a name to be used in an expression, i.e. a=%s", input.path, input.path)
+		}
+		names[input.name] = true
+	}
+
+	for _, name := range expression.Variables() {
+		if !names[name] {
+			return nil, fmt.Errorf("%s is not the name of a counter", name)
+		}
+	}
+
+	return &counterExpression{expression: expression, inputs: inputs}, nil
+}
+
+// evaluate queries the counters and evaluates the expression for each
+// instance found in any of the counters, joined by instance name. A counter
+// without instances, such as \Memory\Commit Limit, is used for every
+// instance. Instances missing from one of the counters, and those the
+// expression cannot be evaluated for, are returned as errors.
+func (e counterExpression) evaluate(connection agentConnection) (CounterResult, []error, error) {
+	results, err := queryCounters(connection, e.inputs)
+	if err != nil {
+		return CounterResult{}, nil, err
+	}
+
+	for _, result := range results {
+		if len(result.Results) == 0 {
+			return CounterResult{}, nil, nil
+		}
+	}
+
+	var evaluated CounterResult
+	var instanceErrors []error
+
+instances:
+	for _, instance := range joinedInstances(results) {
+		variables := make(map[string]float64, len(e.inputs))
+
+		for i, input := range e.inputs {
+			item, found := matchInstance(results[i].Results, instance)
+			if !found {
+				instanceErrors = append(instanceErrors, fmt.Errorf("%s is missing from %s", instance, input.path))
+				continue instances
+			}
+
+			value, err := nagios.ParsePerfDataValue(item.Value)
+			if err != nil {
+				instanceErrors = append(instanceErrors, fmt.Errorf("%s of %s: %w", describeInstance(item.InstanceName), input.path, err))
+				continue instances
+			}
+			variables[input.name] = value
+		}
+
+		value, err := e.expression.Evaluate(variables)
+		if err != nil {
+			instanceErrors = append(instanceErrors, fmt.Errorf("error evaluating %s for %s: %w", e.expression, describeInstance(instance), err))
+			continue
+		}
+
+		evaluated.Results = append(evaluated.Results, CounterResultItem{
+			CounterName:  e.expression.String(),
+			InstanceName: instance,
+			Value:        strconv.FormatFloat(value, 'f', -1, 64),
+		})
+	}
+
+	return evaluated, instanceErrors, nil
+}
+
+// joinedInstances returns the instance names found in any of the results,
+// ignoring case, in the order they are first found. When none of the
+// counters have instances the expression is evaluated once, for the empty
+// instance name.
+func joinedInstances(results []CounterResult) []string {
+	var instances []string
+	found := map[string]bool{}
+	for _, result := range results {
+		for _, item := range result.Results {
+			name := strings.ToLower(item.InstanceName)
+			if item.InstanceName != "" && !found[name] {
+				found[name] = true
+				instances = append(instances, item.InstanceName)
+			}
+		}
+	}
+
+	if len(instances) == 0 {
+		return []string{""}
+	}
+	return instances
+}
+
+// matchInstance returns the value of an instance, ignoring case, or the
+// value of a counter without instances, which applies to every instance.
+func matchInstance(items []CounterResultItem, instance string) (CounterResultItem, bool) {
+	for _, item := range items {
+		if item.InstanceName == "" || strings.EqualFold(item.InstanceName, instance) {
+			return item, true
+		}
+	}
+	return CounterResultItem{}, false
+}
+
+// describeInstance returns an instance name for display, as counters
+// without instances have an empty one.
+func describeInstance(instance string) string {
+	if instance == "" {
+		return "the counters"
+	}
+	return instance
+}
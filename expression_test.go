@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterExpression(t *testing.T) {
+	connection := newMockConnection(map[string][]CounterResultItem{
+		`\Network Interface(*)\Bytes Total/sec`: {
+			{InstanceName: "eth0", Value: "125"},
+			{InstanceName: "eth1", Value: "250"},
+		},
+		`\Network Interface(*)\Current Bandwidth`: {
+			{InstanceName: "ETH1", Value: "4000"},
+			{InstanceName: "eth0", Value: "1000"},
+		},
+		`\Network Interface(eth0)\Current Bandwidth`: {{InstanceName: "eth0", Value: "1000"}},
+		`\Memory\Committed Bytes`:                    {{Value: "50"}},
+		`\Memory\Commit Limit`:                       {{Value: "200"}},
+		`\Test\Zero`:                                 {{Value: "0"}},
+	})
+
+	evaluate := func(t *testing.T, source string, paths ...string) (CounterResult, []error) {
+		inputs, err := newCounterChecks(newCounterFlags(paths...), newCounterFlags(), newCounterFlags(), defaultExpressionUnit, newCounterFlags(), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(inputs))
+		expression, err := newCounterExpression(source, inputs)
+		assert.Nil(t, err)
+
+		result, instanceErrors, err := expression.evaluate(connection)
+		assert.Nil(t, err)
+		return result, instanceErrors
+	}
+
+	t.Run("Instances are joined by name ignoring case", func(t *testing.T) {
+		result, instanceErrors := evaluate(t, "100 * a * 8 / b", `a=\Network Interface(*)\Bytes Total/sec`, `b=\Network Interface(*)\Current Bandwidth`)
+		assert.Empty(t, instanceErrors)
+		assert.Len(t, result.Results, 2)
+		assert.Equal(t, "eth0", result.Results[0].InstanceName)
+		assert.Equal(t, "100", result.Results[0].Value)
+		assert.Equal(t, "eth1", result.Results[1].InstanceName)
+		assert.Equal(t, "50", result.Results[1].Value)
+	})
+
+	t.Run("A counter without instances is used for every instance", func(t *testing.T) {
+		result, instanceErrors := evaluate(t, "a / limit", `a=\Network Interface(*)\Bytes Total/sec`, `limit=\Memory\Commit Limit`)
+		assert.Empty(t, instanceErrors)
+		assert.Equal(t, []string{"0.625", "1.25"}, []string{result.Results[0].Value, result.Results[1].Value})
+
+		result, instanceErrors = evaluate(t, "100 * committed / limit", `committed=\Memory\Committed Bytes`, `limit=\Memory\Commit Limit`)
+		assert.Empty(t, instanceErrors)
+		assert.Equal(t, []CounterResultItem{{CounterName: "100 * committed / limit", Value: "25"}}, result.Results)
+	})
+
+	t.Run("A single instance is not used for other instances", func(t *testing.T) {
+		result, instanceErrors := evaluate(t, "a / b", `a=\Network Interface(*)\Bytes Total/sec`, `b=\Network Interface(eth0)\Current Bandwidth`)
+		assert.Len(t, result.Results, 1)
+		assert.Equal(t, "eth0", result.Results[0].InstanceName)
+		if assert.Len(t, instanceErrors, 1) {
+			assert.Contains(t, instanceErrors[0].Error(), `eth1 is missing from \Network Interface(eth0)\Current Bandwidth`)
+		}
+	})
+
+	t.Run("Missing instances and division by zero leave the check UNKNOWN", func(t *testing.T) {
+		check := func(source string, paths ...string) (string, int) {
+			inputs, err := newCounterChecks(newCounterFlags(paths...), newCounterFlags(), newCounterFlags(), defaultExpressionUnit, newCounterFlags(), newCounterFlags())
+			assert.Nil(t, err)
+			assert.Nil(t, parseCounterPaths(inputs))
+
+			options := testCounterOptions()
+			options.expression, err = newCounterExpression(source, inputs)
+			assert.Nil(t, err)
+			checks, err := newCounterChecks(newCounterFlags(source), newCounterFlags("result"), newCounterFlags(), defaultExpressionUnit, newCounterFlags("1000"), newCounterFlags())
+			assert.Nil(t, err)
+
+			output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+				return checkCounters(plugin, connection, checks, options)
+			})
+			assert.Nil(t, err)
+			return output, exitCode
+		}
+
+		output, exitCode := check("a / b", `a=\Network Interface(*)\Bytes Total/sec`, `b=\Network Interface(eth0)\Current Bandwidth`)
+		assert.Equal(t, nagios.StateUNKNOWNExitCode, exitCode)
+		assert.Contains(t, output, "eth1 is missing from")
+
+		output, exitCode = check("limit / zero", `limit=\Memory\Commit Limit`, `zero=\Test\Zero`)
+		assert.Equal(t, nagios.StateUNKNOWNExitCode, exitCode)
+		assert.Contains(t, output, "error evaluating")
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// instanceFilter decides which instances of a wildcard counter are checked.
+// An instance is kept if it matches any of the include expressions (or there
+// are none) and none of the exclude expressions. Instance names are matched
+// ignoring case, as they are on Windows.
+type instanceFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func compileInstancePatterns(name string, patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		expression, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s expression %q %s", name, pattern, err.Error())
+		}
+		compiled = append(compiled, expression)
+	}
+	return compiled, nil
+}
+
+func newInstanceFilter(include []string, exclude []string) (instanceFilter, error) {
+	var filter instanceFilter
+	var err error
+
+	if filter.include, err = compileInstancePatterns("include-instance", include); err != nil {
+		return filter, err
+	}
+	if filter.exclude, err = compileInstancePatterns("exclude-instance", exclude); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// active reports whether any filtering has been requested.
+func (filter instanceFilter) active() bool {
+	return len(filter.include) > 0 || len(filter.exclude) > 0
+}
+
+func (filter instanceFilter) matches(instanceName string) bool {
+	for _, expression := range filter.exclude {
+		if expression.MatchString(instanceName) {
+			return false
+		}
+	}
+
+	if len(filter.include) == 0 {
+		return true
+	}
+
+	for _, expression := range filter.include {
+		if expression.MatchString(instanceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns the results whose instance is kept by the filter.
+func (filter instanceFilter) apply(results []CounterResultItem) []CounterResultItem {
+	if !filter.active() {
+		return results
+	}
+
+	var kept []CounterResultItem
+	for _, result := range results {
+		if filter.matches(result.InstanceName) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
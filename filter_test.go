@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceFilter(t *testing.T) {
+	disks := []CounterResultItem{
+		{InstanceName: "C:", Value: "40"},
+		{InstanceName: "D:", Value: "60"},
+		{InstanceName: "HarddiskVolume1", Value: "90"},
+		{InstanceName: "_Total", Value: "50"},
+	}
+
+	names := func(items []CounterResultItem) []string {
+		var kept []string
+		for _, item := range items {
+			kept = append(kept, item.InstanceName)
+		}
+		return kept
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{"Without expressions every instance is kept", nil, nil, []string{"C:", "D:", "HarddiskVolume1", "_Total"}},
+		{"Only included instances are kept", []string{"^[a-z]:$"}, nil, []string{"C:", "D:"}},
+		{"Excluded instances are dropped", nil, []string{"^_total$", "^harddisk"}, []string{"C:", "D:"}},
+		{"Exclusions apply to included instances", []string{":$", "volume"}, []string{"^d:$"}, []string{"C:", "HarddiskVolume1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := newInstanceFilter(test.include, test.exclude)
+			assert.Nil(t, err)
+			assert.Equal(t, len(test.include) > 0 || len(test.exclude) > 0, filter.active())
+			assert.Equal(t, test.expected, names(filter.apply(disks)))
+		})
+	}
+
+	t.Run("Invalid expressions are reported", func(t *testing.T) {
+		_, err := newInstanceFilter([]string{"("}, nil)
+		assert.Contains(t, err.Error(), `invalid include-instance expression "("`)
+
+		_, err = newInstanceFilter(nil, []string{"[a-"})
+		assert.Contains(t, err.Error(), `invalid exclude-instance expression "[a-"`)
+	})
+
+	t.Run("No instances left after filtering is reported in the empty state", func(t *testing.T) {
+		connection := newMockConnection(map[string][]CounterResultItem{`\LogicalDisk(*)\% Free Space`: disks})
+		checks, err := newCounterChecks(newCounterFlags(`\LogicalDisk(*)\% Free Space`), newCounterFlags(), newCounterFlags(), defaultCounterUnit, newCounterFlags(), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		options := testCounterOptions()
+		options.filter, err = newInstanceFilter([]string{"^z:$"}, nil)
+		assert.Nil(t, err)
+		options.emptyState, _ = nagios.ParseServiceState(nagios.StateWARNINGLabel)
+
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, options)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateWARNINGExitCode, exitCode)
+		assert.Contains(t, output, `no instances of \LogicalDisk(*)\% Free Space are left after filtering`)
+	})
+}
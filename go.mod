@@ -2,10 +2,10 @@ module monitoring-agent-client-check-nt-replacement
 
 go 1.17
 
-require github.com/stretchr/testify v1.7.1
-
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/stretchr/testify v1.7.1
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/davecgh/go-spew v1.1.0 // indirect
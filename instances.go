@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+	"strings"
+)
+
+// defaultInstancesCounter is requested for every instance of the object when
+// no counter is given, as every instance has at least one counter.
+const defaultInstancesCounter = "*"
+
+// fetchInstances returns the distinct instance names of a counter object in
+// the order the agent returned them.
+func fetchInstances(connection agentConnection, object string, counter string) ([]string, error) {
+	decodedResponse, err := connection.queryCounter(fmt.Sprintf(`\%s(*)\%s`, object, counter))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var instances []string
+	for _, outputValue := range decodedResponse.Results {
+		if outputValue.InstanceName == "" || seen[outputValue.InstanceName] {
+			continue
+		}
+		seen[outputValue.InstanceName] = true
+		instances = append(instances, outputValue.InstanceName)
+	}
+
+	return instances, nil
+}
+
+// checkInstances emulates the check_nt INSTANCES variable, listing the
+// instances of a counter object. The check is always OK so that it can be
+// used for discovery.
+func checkInstances(plugin *nagios.Plugin, connection agentConnection, params string) error {
+	parts := splitCheckNtParams(params)
+	object := strings.Trim(parts[0], `\`)
+	if object == "" {
+		return fmt.Errorf("INSTANCES requires a counter object (i.e. Process)")
+	}
+
+	counter := defaultInstancesCounter
+	if len(parts) > 1 && parts[1] != "" {
+		counter = strings.Trim(parts[1], `\`)
+	}
+
+	instances, err := fetchInstances(connection, object, counter)
+	if err != nil {
+		return err
+	}
+
+	plugin.AddPerfData(false, nagios.PerformanceData{
+		Label: "instances",
+		Value: strconv.Itoa(len(instances)),
+		Min:   "0",
+	})
+
+	plugin.ExitStatusCode = nagios.StateOKExitCode
+	setServiceOutput(plugin, fmt.Sprintf("%s instances: %s", object, strings.Join(instances, ", ")))
+	return nil
+}
@@ -0,0 +1,164 @@
+/*
+Package countermap translates the English object and counter names of
+Windows performance counter paths into the names used by a localized
+installation, such as Prozessor and Prozessorzeit (%) on German Windows.
+
+Every name has an index which is the same in every language, so a map is
+built from a file listing the index, English name and localized name of
+each counter, one per line in CSV form:
+
+	# index,english,localized
+	238,Processor,Prozessor
+	6,% Processor Time,Prozessorzeit (%)
+
+The names are exported from the Counter values of the Perflib\009 and
+Perflib\CurrentLanguage registry keys. Objects and counters may also be
+given by their index in a path, i.e. \238(_Total)\6.
+*/
+package countermap
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCounterMap indicates that a counter map could not be parsed.
+var ErrInvalidCounterMap = errors.New("invalid counter map")
+
+// Map translates English object and counter names into localized ones.
+type Map struct {
+	byEnglish      map[string]string
+	byIndex        map[int]string
+	englishByIndex map[int]string
+}
+
+// Parse reads a counter map in CSV form. When an English name is listed
+// more than once the first localized name is used.
+func Parse(r io.Reader) (*Map, error) {
+	// Windows PowerShell writes UTF-8 files with a byte order mark.
+	buffered := bufio.NewReader(r)
+	if character, _, err := buffered.ReadRune(); err == nil && character != '\ufeff' {
+		buffered.UnreadRune()
+	}
+
+	// Every entry is on a line of its own, so the lines are read one at a
+	// time to know the line number of an invalid entry.
+	scanner := bufio.NewScanner(buffered)
+	counterMap := &Map{byEnglish: map[string]string{}, byIndex: map[int]string{}, englishByIndex: map[int]string{}}
+	for line := 1; scanner.Scan(); line++ {
+		reader := csv.NewReader(strings.NewReader(scanner.Text()))
+		reader.Comment = '#'
+		reader.FieldsPerRecord = -1
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			// The position reported by the reader is always on its first line.
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				err = parseError.Err
+			}
+			return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidCounterMap, line, err.Error())
+		}
+		if len(record) != 3 {
+			return nil, fmt.Errorf("%w: line %d has %d fields instead of 3", ErrInvalidCounterMap, line, len(record))
+		}
+
+		index, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: index %q on line %d is not a number", ErrInvalidCounterMap, record[0], line)
+		}
+
+		english := strings.TrimSpace(record[1])
+		localized := strings.TrimSpace(record[2])
+		if localized == "" {
+			localized = english
+		}
+
+		if _, found := counterMap.byIndex[index]; !found {
+			counterMap.byIndex[index] = localized
+			if english != "" {
+				counterMap.englishByIndex[index] = english
+			}
+		}
+		if _, found := counterMap.byEnglish[strings.ToLower(english)]; !found && english != "" {
+			counterMap.byEnglish[strings.ToLower(english)] = localized
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCounterMap, err.Error())
+	}
+	return counterMap, nil
+}
+
+// LoadFile reads a counter map from a file.
+func LoadFile(path string) (*Map, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// Localize returns the path with its object and counter names translated.
+// Names which are not in the map, such as names which are already
+// localized, are kept. Instance names are never translated. A nil Map
+// returns the path unchanged.
+func (m *Map) Localize(path counterpath.CounterPath) counterpath.CounterPath {
+	if m == nil {
+		return path
+	}
+
+	path.Object = m.localizeName(path.Object)
+	path.Counter = m.localizeName(path.Counter)
+	return path
+}
+
+// localizeName translates a single name, given either in English or by its
+// index.
+func (m *Map) localizeName(name string) string {
+	if index, err := strconv.Atoi(name); err == nil {
+		if localized, found := m.byIndex[index]; found {
+			return localized
+		}
+		return name
+	}
+
+	if localized, found := m.byEnglish[strings.ToLower(name)]; found {
+		return localized
+	}
+	return name
+}
+
+// English returns the path with any object or counter given by its index
+// replaced by its English name, so that labels built from the path read
+// the same as with English names. A nil Map returns the path unchanged.
+func (m *Map) English(path counterpath.CounterPath) counterpath.CounterPath {
+	if m == nil {
+		return path
+	}
+
+	english := func(name string) string {
+		if index, err := strconv.Atoi(name); err == nil {
+			if englishName, found := m.englishByIndex[index]; found {
+				return englishName
+			}
+		}
+		return name
+	}
+
+	path.Object = english(path.Object)
+	path.Counter = english(path.Counter)
+	return path
+}
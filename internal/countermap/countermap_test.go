@@ -0,0 +1,76 @@
+package countermap
+
+import (
+	"strings"
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const germanMap = `# index,english,localized
+238,Processor,Prozessor
+6,% Processor Time,Prozessorzeit (%)
+4,Memory,Speicher
+1406,"Committed Bytes, Total",
+6,% Processor Time,Prozessorzeit
+`
+
+func TestCounterMap(t *testing.T) {
+	localize := func(t *testing.T, counterMap *Map, input string) string {
+		path, err := counterpath.Parse(input)
+		assert.Nil(t, err)
+		return counterMap.Localize(path).String()
+	}
+
+	counterMap, err := Parse(strings.NewReader(germanMap))
+	assert.Nil(t, err)
+
+	t.Run("English names are translated ignoring case", func(t *testing.T) {
+		assert.Equal(t, `\Prozessor(_Total)\Prozessorzeit (%)`, localize(t, counterMap, `\processor(_Total)\% Processor Time`))
+	})
+
+	t.Run("Names may be given by their index", func(t *testing.T) {
+		assert.Equal(t, `\\web01\Prozessor(*)\Prozessorzeit (%)`, localize(t, counterMap, `\\web01\238(*)\6`))
+	})
+
+	t.Run("Indexes are named in English", func(t *testing.T) {
+		path, err := counterpath.Parse(`\238(*)\6`)
+		assert.Nil(t, err)
+		assert.Equal(t, `\Processor(*)\% Processor Time`, counterMap.English(path).String())
+	})
+
+	t.Run("Unknown names and instances are kept", func(t *testing.T) {
+		assert.Equal(t, `\Speicher\Verfügbare MB`, localize(t, counterMap, `\Memory\Verfügbare MB`))
+		assert.Equal(t, `\Prozessor(Processor)\Prozessorzeit (%)`, localize(t, counterMap, `\Processor(Processor)\% Processor Time`))
+		assert.Equal(t, `\Speicher\Committed Bytes, Total`, localize(t, counterMap, `\Memory\Committed Bytes, Total`))
+	})
+
+	t.Run("A nil map keeps the path", func(t *testing.T) {
+		var nilMap *Map
+		assert.Equal(t, `\Memory\Committed Bytes`, localize(t, nilMap, `\Memory\Committed Bytes`))
+	})
+
+	t.Run("A byte order mark is ignored", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("\ufeff\"238\",\"Processor\",\"Prozessor\"\n"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Invalid maps are reported", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("238,Processor,Prozessor\nx,Memory,Speicher\n"))
+		assert.ErrorIs(t, err, ErrInvalidCounterMap)
+		assert.Contains(t, err.Error(), "line 2")
+
+		_, err = Parse(strings.NewReader("238,Processor\n"))
+		assert.ErrorIs(t, err, ErrInvalidCounterMap)
+
+		_, err = Parse(strings.NewReader("# index,english,localized\r\n\r\n238,Processor\r\n"))
+		assert.ErrorIs(t, err, ErrInvalidCounterMap)
+		assert.Contains(t, err.Error(), "line 3 has 2 fields")
+
+		_, err = Parse(strings.NewReader("238,Processor,Prozessor\n6,\"% Processor\" Time,x\n"))
+		assert.ErrorIs(t, err, ErrInvalidCounterMap)
+		assert.Contains(t, err.Error(), "line 2: ")
+	})
+}
@@ -0,0 +1,277 @@
+/*
+Package counterpath parses and validates Windows performance counter paths
+of the form
+
+	\\computer\object(parent/instance#index)\counter
+
+where the computer, the instance and the parent and index of the instance
+are optional. Within the parentheses a backslash escapes the next
+character, so that instance names containing ( ) / # or \ can be given, and
+the * and ? wildcards may be used in the instance and counter names.
+*/
+package counterpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCounterPath indicates that a counter path could not be parsed.
+var ErrInvalidCounterPath = errors.New("invalid counter path")
+
+// CounterPath is a parsed counter path.
+type CounterPath struct {
+
+	// Computer is the name of the computer without the leading backslashes,
+	// or empty for the local computer.
+	Computer string
+
+	// Object is the name of the performance object, i.e. Process.
+	Object string
+
+	// Instance is the instance of the object, or nil for objects without
+	// instances such as Memory.
+	Instance *Instance
+
+	// Counter is the name of the counter, i.e. % Processor Time.
+	Counter string
+}
+
+// Instance is the instance part of a counter path, or an instance name as
+// returned for a counter.
+type Instance struct {
+
+	// Parent is the parent instance, i.e. the process of a thread.
+	Parent string
+
+	// Name is the name of the instance, i.e. svchost.
+	Name string
+
+	// Index tells apart instances with the same name, it is only meaningful
+	// when HasIndex is set.
+	Index    int
+	HasIndex bool
+}
+
+// pathError returns an ErrInvalidCounterPath error describing the problem
+// found at a position of the path, counted in characters from 1.
+func pathError(path string, position int, format string, a ...interface{}) error {
+	return fmt.Errorf("%w \"%s\": %s at position %d", ErrInvalidCounterPath, path, fmt.Sprintf(format, a...), position)
+}
+
+// Parse parses a counter path.
+func Parse(path string) (CounterPath, error) {
+	var counterPath CounterPath
+	runes := []rune(path)
+	position := 0
+
+	// readUntil returns the text up to, but not including, the first of the
+	// stop characters or the end of the path.
+	readUntil := func(stop string) string {
+		start := position
+		for position < len(runes) && !strings.ContainsRune(stop, runes[position]) {
+			position++
+		}
+		return string(runes[start:position])
+	}
+
+	if strings.HasPrefix(path, `\\`) {
+		position = 2
+		counterPath.Computer = readUntil(`\`)
+		if counterPath.Computer == "" {
+			return CounterPath{}, pathError(path, position+1, "missing computer name")
+		}
+	}
+
+	if position >= len(runes) || runes[position] != '\\' {
+		return CounterPath{}, pathError(path, position+1, `expected "\" before the object name`)
+	}
+	position++
+
+	counterPath.Object = readUntil(`(\`)
+	if strings.TrimSpace(counterPath.Object) == "" {
+		return CounterPath{}, pathError(path, position+1, "missing object name")
+	}
+
+	if position < len(runes) && runes[position] == '(' {
+		instance, end, err := parseInstanceSpecification(path, runes, position)
+		if err != nil {
+			return CounterPath{}, err
+		}
+		counterPath.Instance = &instance
+		position = end
+	}
+
+	if position >= len(runes) || runes[position] != '\\' {
+		if position < len(runes) {
+			return CounterPath{}, pathError(path, position+1, "unexpected %q after the object", string(runes[position]))
+		}
+		return CounterPath{}, pathError(path, position+1, "missing counter name")
+	}
+	position++
+
+	counterPath.Counter = readUntil(`\`)
+	if position < len(runes) {
+		return CounterPath{}, pathError(path, position+1, `unexpected "\" in the counter name`)
+	}
+	if strings.TrimSpace(counterPath.Counter) == "" {
+		return CounterPath{}, pathError(path, position+1, "missing counter name")
+	}
+
+	return counterPath, nil
+}
+
+// parseInstanceSpecification parses the parenthesised instance starting at
+// open, returning the position following the closing parenthesis.
+// Unescaped parentheses within the instance must be balanced.
+func parseInstanceSpecification(path string, runes []rune, open int) (Instance, int, error) {
+	var instance Instance
+	var current strings.Builder
+	var index strings.Builder
+	indexStart := 0
+	inIndex := false
+	depth := 0
+
+	for position := open + 1; position < len(runes); position++ {
+		character := runes[position]
+
+		switch {
+		case character == '\\':
+			position++
+			if position >= len(runes) {
+				return Instance{}, 0, pathError(path, position, "escape at the end of the path")
+			}
+			if inIndex {
+				return Instance{}, 0, pathError(path, position, "unexpected escape in the index")
+			}
+			current.WriteRune(runes[position])
+		case character == ')' && depth == 0:
+			instance.Name = current.String()
+			if instance.Name == "" {
+				return Instance{}, 0, pathError(path, position+1, "missing instance name")
+			}
+			if inIndex {
+				value, err := strconv.Atoi(index.String())
+				if err != nil || value < 0 {
+					return Instance{}, 0, pathError(path, indexStart+1, "index %q is not a number", index.String())
+				}
+				instance.Index = value
+				instance.HasIndex = true
+			}
+			return instance, position + 1, nil
+		case inIndex:
+			index.WriteRune(character)
+		case character == '(':
+			depth++
+			current.WriteRune(character)
+		case character == ')':
+			depth--
+			current.WriteRune(character)
+		case character == '/' && depth == 0 && instance.Parent == "":
+			instance.Parent = current.String()
+			if instance.Parent == "" {
+				return Instance{}, 0, pathError(path, position+1, "missing parent instance name")
+			}
+			current.Reset()
+		case character == '#' && depth == 0:
+			inIndex = true
+			indexStart = position + 1
+		default:
+			current.WriteRune(character)
+		}
+	}
+
+	return Instance{}, 0, pathError(path, open+1, "unbalanced parenthesis")
+}
+
+// ParseInstance splits an instance name as returned for a counter, such as
+// svchost#1, into its parent, name and index. Names which do not end in a
+// numeric index are kept whole.
+func ParseInstance(name string) Instance {
+	var instance Instance
+
+	if slash := strings.Index(name, "/"); slash > 0 {
+		instance.Parent = name[:slash]
+		name = name[slash+1:]
+	}
+
+	if hash := strings.LastIndex(name, "#"); hash > 0 {
+		if index, err := strconv.Atoi(name[hash+1:]); err == nil && index >= 0 {
+			instance.Index = index
+			instance.HasIndex = true
+			name = name[:hash]
+		}
+	}
+
+	instance.Name = name
+	return instance
+}
+
+// String returns the instance in the form parent/name#index, without any
+// escaping.
+func (i Instance) String() string {
+	var output strings.Builder
+	if i.Parent != "" {
+		output.WriteString(i.Parent)
+		output.WriteByte('/')
+	}
+	output.WriteString(i.Name)
+	if i.HasIndex {
+		fmt.Fprintf(&output, "#%d", i.Index)
+	}
+	return output.String()
+}
+
+// String returns the counter path as it is passed to Windows, without any
+// escaping.
+func (p CounterPath) String() string {
+	var output strings.Builder
+	if p.Computer != "" {
+		output.WriteString(`\\`)
+		output.WriteString(p.Computer)
+	}
+	output.WriteByte('\\')
+	output.WriteString(p.Object)
+	if p.Instance != nil {
+		output.WriteByte('(')
+		output.WriteString(p.Instance.String())
+		output.WriteByte(')')
+	}
+	output.WriteByte('\\')
+	output.WriteString(p.Counter)
+	return output.String()
+}
+
+// Match reports whether a name matches a pattern where * matches any
+// sequence of characters and ? any single character, ignoring case.
+func Match(pattern string, name string) bool {
+	patternRunes := []rune(strings.ToLower(pattern))
+	nameRunes := []rune(strings.ToLower(name))
+
+	// The position to resume from when a * needs to match one more
+	// character.
+	star, resume := -1, 0
+	p, n := 0, 0
+	for n < len(nameRunes) {
+		switch {
+		case p < len(patternRunes) && (patternRunes[p] == '?' || patternRunes[p] == nameRunes[n]):
+			p++
+			n++
+		case p < len(patternRunes) && patternRunes[p] == '*':
+			star, resume = p, n
+			p++
+		case star >= 0:
+			resume++
+			p, n = star+1, resume
+		default:
+			return false
+		}
+	}
+
+	for p < len(patternRunes) && patternRunes[p] == '*' {
+		p++
+	}
+	return p == len(patternRunes)
+}
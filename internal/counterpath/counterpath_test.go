@@ -0,0 +1,72 @@
+package counterpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterPath(t *testing.T) {
+	t.Run("Paths without instances are parsed", func(t *testing.T) {
+		path, err := Parse(`\Memory\Committed Bytes`)
+		assert.Nil(t, err)
+		assert.Equal(t, CounterPath{Object: "Memory", Counter: "Committed Bytes"}, path)
+		assert.Equal(t, `\Memory\Committed Bytes`, path.String())
+	})
+
+	t.Run("Every part of a full path is parsed", func(t *testing.T) {
+		path, err := Parse(`\\web01\Thread(svchost/3#1)\% Processor Time`)
+		assert.Nil(t, err)
+		assert.Equal(t, CounterPath{
+			Computer: "web01",
+			Object:   "Thread",
+			Instance: &Instance{Parent: "svchost", Name: "3", Index: 1, HasIndex: true},
+			Counter:  "% Processor Time",
+		}, path)
+		assert.Equal(t, `\\web01\Thread(svchost/3#1)\% Processor Time`, path.String())
+	})
+
+	t.Run("Escaped and balanced characters are part of the instance", func(t *testing.T) {
+		path, err := Parse(`\Processor Information(Intel(R) Xeon\#2\/x\))\% Processor Time`)
+		assert.Nil(t, err)
+		assert.Equal(t, "Intel(R) Xeon#2/x)", path.Instance.Name)
+		assert.False(t, path.Instance.HasIndex)
+		assert.Equal(t, `\Processor Information(Intel(R) Xeon#2/x))\% Processor Time`, path.String())
+	})
+
+	t.Run("Invalid paths are reported with their position", func(t *testing.T) {
+		for input, message := range map[string]string{
+			`Memory\Committed Bytes`:      `expected "\" before the object name at position 1`,
+			`\\\Memory\Committed Bytes`:   `missing computer name at position 3`,
+			`\(x)\Counter`:                `missing object name at position 2`,
+			`\Memory`:                     `missing counter name at position 8`,
+			`\Memory\`:                    `missing counter name at position 9`,
+			`\Process(*\% Processor Time`: `unbalanced parenthesis at position 9`,
+			`\Process()\ID Process`:       `missing instance name at position 10`,
+			`\Process(svchost#a)\ID`:      `index "a" is not a number at position 18`,
+			`\Process(x)y\ID Process`:     `unexpected "y" after the object at position 12`,
+			`\Memory\Committed\Bytes`:     `unexpected "\" in the counter name at position 18`,
+		} {
+			_, err := Parse(input)
+			assert.ErrorIs(t, err, ErrInvalidCounterPath, input)
+			if err != nil {
+				assert.Contains(t, err.Error(), message, input)
+			}
+		}
+	})
+
+	t.Run("Instance names are split into parent, name and index", func(t *testing.T) {
+		assert.Equal(t, Instance{Name: "svchost", Index: 2, HasIndex: true}, ParseInstance("svchost#2"))
+		assert.Equal(t, Instance{Parent: "chrome", Name: "4"}, ParseInstance("chrome/4"))
+		assert.Equal(t, Instance{Name: "c#"}, ParseInstance("c#"))
+	})
+
+	t.Run("Wildcard patterns match any sequence or single character", func(t *testing.T) {
+		assert.True(t, Match("*", ""))
+		assert.True(t, Match("harddisk*", "HarddiskVolume1"))
+		assert.True(t, Match("?:", "c:"))
+		assert.True(t, Match("a*b*c", "aXbYbZc"))
+		assert.False(t, Match("a*b", "ac"))
+		assert.False(t, Match("?", ""))
+	})
+}
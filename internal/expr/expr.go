@@ -0,0 +1,177 @@
+/*
+Package expr evaluates arithmetic expressions over named values, such as
+100 * committed / limit, so that metrics can be derived from several
+counters.
+
+Expressions support numbers, variables, the + - * / operators with the
+usual precedence, unary minus, parentheses and the min, max and abs
+functions.
+*/
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrSyntax indicates that an expression could not be parsed.
+	ErrSyntax = errors.New("invalid expression")
+
+	// ErrDivisionByZero indicates that an expression divided by zero for the
+	// given values.
+	ErrDivisionByZero = errors.New("division by zero")
+
+	// ErrUndefinedVariable indicates that no value was given for a variable
+	// used by an expression.
+	ErrUndefinedVariable = errors.New("undefined variable")
+)
+
+// Expression is a parsed expression which can be evaluated any number of
+// times.
+type Expression struct {
+	source    string
+	root      node
+	variables []string
+}
+
+// node is a single operation within the expression tree.
+type node interface {
+	evaluate(variables map[string]float64) (float64, error)
+}
+
+type number float64
+
+type variable string
+
+type negation struct {
+	operand node
+}
+
+type binary struct {
+	operator byte
+	left     node
+	right    node
+}
+
+type call struct {
+	function  string
+	arguments []node
+}
+
+// functions are the functions which may be called, along with the number
+// of arguments they take or -1 for one or more.
+var functions = map[string]int{
+	"min": -1,
+	"max": -1,
+	"abs": 1,
+}
+
+// Parse parses an expression.
+func Parse(source string) (*Expression, error) {
+	p := parser{tokens: tokenize(source), variables: map[string]bool{}}
+
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEnd {
+		return nil, p.unexpected(next)
+	}
+
+	var variables []string
+	for name := range p.variables {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+
+	return &Expression{source: source, root: root, variables: variables}, nil
+}
+
+// String returns the expression as it was given.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Variables returns the sorted names of the variables used by the
+// expression.
+func (e *Expression) Variables() []string {
+	return e.variables
+}
+
+// Evaluate returns the value of the expression for the given variables.
+func (e *Expression) Evaluate(variables map[string]float64) (float64, error) {
+	return e.root.evaluate(variables)
+}
+
+func (n number) evaluate(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (v variable) evaluate(variables map[string]float64) (float64, error) {
+	value, ok := variables[string(v)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUndefinedVariable, string(v))
+	}
+	return value, nil
+}
+
+func (n negation) evaluate(variables map[string]float64) (float64, error) {
+	value, err := n.operand.evaluate(variables)
+	return -value, err
+}
+
+func (b binary) evaluate(variables map[string]float64) (float64, error) {
+	left, err := b.left.evaluate(variables)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.right.evaluate(variables)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.operator {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	default:
+		if right == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return left / right, nil
+	}
+}
+
+func (c call) evaluate(variables map[string]float64) (float64, error) {
+	values := make([]float64, len(c.arguments))
+	for i, argument := range c.arguments {
+		value, err := argument.evaluate(variables)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = value
+	}
+
+	switch c.function {
+	case "abs":
+		return math.Abs(values[0]), nil
+	case "min":
+		result := values[0]
+		for _, value := range values[1:] {
+			result = math.Min(result, value)
+		}
+		return result, nil
+	default:
+		result := values[0]
+		for _, value := range values[1:] {
+			result = math.Max(result, value)
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,67 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpression(t *testing.T) {
+	evaluate := func(source string, variables map[string]float64) (float64, error) {
+		expression, err := Parse(source)
+		if err != nil {
+			return 0, err
+		}
+		return expression.Evaluate(variables)
+	}
+
+	t.Run("Operators follow the usual precedence", func(t *testing.T) {
+		value, err := evaluate("1 + 2 * 3 - 4 / 2", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 5.0, value)
+
+		value, err = evaluate("(1 + 2) * -3", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, -9.0, value)
+
+		value, err = evaluate("8 / 4 / 2", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1.0, value)
+	})
+
+	t.Run("Variables are substituted", func(t *testing.T) {
+		value, err := evaluate("100 * committed / limit", map[string]float64{"committed": 6, "limit": 8})
+		assert.Nil(t, err)
+		assert.Equal(t, 75.0, value)
+	})
+
+	t.Run("Functions are evaluated", func(t *testing.T) {
+		value, err := evaluate("max(a, b, 3) + min(a, b) + abs(-2.5)", map[string]float64{"a": 1, "b": 2})
+		assert.Nil(t, err)
+		assert.Equal(t, 6.5, value)
+	})
+
+	t.Run("Variables are listed once in order", func(t *testing.T) {
+		expression, err := Parse("b * 8 / a + b")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, expression.Variables())
+		assert.Equal(t, "b * 8 / a + b", expression.String())
+	})
+
+	t.Run("Division by zero is an error", func(t *testing.T) {
+		_, err := evaluate("a / b", map[string]float64{"a": 1, "b": 0})
+		assert.ErrorIs(t, err, ErrDivisionByZero)
+	})
+
+	t.Run("Missing variables are an error", func(t *testing.T) {
+		_, err := evaluate("a + b", map[string]float64{"a": 1})
+		assert.ErrorIs(t, err, ErrUndefinedVariable)
+	})
+
+	t.Run("Invalid expressions are syntax errors", func(t *testing.T) {
+		for _, source := range []string{"", "1 +", "(1", "1 2", "a $ b", "sqrt(4)", "abs(1, 2)", "min()", "1..2"} {
+			_, err := Parse(source)
+			assert.ErrorIs(t, err, ErrSyntax, source)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenIdentifier
+	tokenOperator
+	tokenInvalid
+)
+
+// token is a single element of an expression along with its position, used
+// to point at the problem in syntax errors.
+type token struct {
+	kind     tokenKind
+	text     string
+	position int
+}
+
+// tokenize splits an expression into numbers, identifiers and operators,
+// ending with a tokenEnd token.
+func tokenize(source string) []token {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		character := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(character):
+			i++
+			continue
+		case unicode.IsDigit(character) || character == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), position: start + 1})
+		case unicode.IsLetter(character) || character == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, text: string(runes[start:i]), position: start + 1})
+		case strings.ContainsRune("+-*/(),", character):
+			i++
+			tokens = append(tokens, token{kind: tokenOperator, text: string(character), position: start + 1})
+		default:
+			i++
+			tokens = append(tokens, token{kind: tokenInvalid, text: string(character), position: start + 1})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd, position: len(runes) + 1})
+}
+
+// parser is a recursive descent parser over the tokens of an expression.
+type parser struct {
+	tokens    []token
+	next      int
+	variables map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	current := p.tokens[p.next]
+	if current.kind != tokenEnd {
+		p.next++
+	}
+	return current
+}
+
+// accept consumes the next token if it is the given operator.
+func (p *parser) accept(operator string) bool {
+	if next := p.peek(); next.kind == tokenOperator && next.text == operator {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEnd {
+		return fmt.Errorf("%w: unexpected end", ErrSyntax)
+	}
+	return fmt.Errorf("%w: unexpected %q at position %d", ErrSyntax, t.text, t.position)
+}
+
+// parseExpression parses a sum or difference of terms.
+func (p *parser) parseExpression() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var operator byte
+		switch {
+		case p.accept("+"):
+			operator = '+'
+		case p.accept("-"):
+			operator = '-'
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{operator: operator, left: left, right: right}
+	}
+}
+
+// parseTerm parses a product or quotient of factors.
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var operator byte
+		switch {
+		case p.accept("*"):
+			operator = '*'
+		case p.accept("/"):
+			operator = '/'
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{operator: operator, left: left, right: right}
+	}
+}
+
+// parseFactor parses a signed number, variable, function call or
+// parenthesised expression.
+func (p *parser) parseFactor() (node, error) {
+	switch {
+	case p.accept("-"):
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand: operand}, nil
+	case p.accept("+"):
+		return p.parseFactor()
+	case p.accept("("):
+		inner, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected(p.peek())
+		}
+		return inner, nil
+	}
+
+	current := p.advance()
+	switch current.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(current.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q at position %d", ErrSyntax, current.text, current.position)
+		}
+		return number(value), nil
+	case tokenIdentifier:
+		if p.accept("(") {
+			return p.parseCall(current)
+		}
+		p.variables[current.text] = true
+		return variable(current.text), nil
+	default:
+		return nil, p.unexpected(current)
+	}
+}
+
+// parseCall parses the arguments of a function call, the opening
+// parenthesis has already been consumed.
+func (p *parser) parseCall(name token) (node, error) {
+	function := strings.ToLower(name.text)
+	arity, known := functions[function]
+	if !known {
+		return nil, fmt.Errorf("%w: unknown function %q at position %d", ErrSyntax, name.text, name.position)
+	}
+
+	var arguments []node
+	if !p.accept(")") {
+		for {
+			argument, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			arguments = append(arguments, argument)
+
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return nil, p.unexpected(p.peek())
+			}
+		}
+	}
+
+	if len(arguments) == 0 || (arity > 0 && len(arguments) != arity) {
+		return nil, fmt.Errorf("%w: wrong number of arguments to %s at position %d", ErrSyntax, function, name.position)
+	}
+
+	return call{function: function, arguments: arguments}, nil
+}
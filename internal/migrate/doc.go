@@ -0,0 +1,11 @@
+/*
+Package migrate rewrites Nagios and Icinga 1.x object configuration which
+uses check_nt so that it uses monitoring-agent-check-nt-replacement instead.
+
+Command definitions which invoke check_nt have the plugin path replaced and
+the connection related flags translated onto their native equivalents. The
+check_nt -v variable of each check is resolved, following $ARGn$ macros
+through the check_command of the services and hosts using the command, and
+any variable which is not supported is reported as an Issue.
+*/
+package migrate
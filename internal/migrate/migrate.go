@@ -0,0 +1,369 @@
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// checkNtName is the file name of the plugin being migrated from.
+const checkNtName = "check_nt"
+
+// argMacro matches a $ARGn$ macro.
+var argMacro = regexp.MustCompile(`^\$ARG(\d+)\$$`)
+
+// renamedFlags are the check_nt flags which are translated onto the native
+// flags of the plugin. The remaining check_nt flags are understood by the
+// plugin as they are, and keep their check_nt semantics.
+var renamedFlags = map[string]string{
+	"-H":         "-host",
+	"--hostname": "-host",
+	"-p":         "-port",
+	"--port":     "-port",
+	"-s":         "-password",
+	"--secret":   "-password",
+}
+
+// timeoutFlags are translated to -timeout, with the number of seconds they
+// take turned into a duration.
+var timeoutFlags = map[string]bool{
+	"-t":        true,
+	"--timeout": true,
+}
+
+// keptFlags are the check_nt flags which are kept as they are, along with
+// the short form used for their long options.
+var keptFlags = map[string]string{
+	"-v":         "-v",
+	"--variable": "-v",
+	"-l":         "-l",
+	"--params":   "-l",
+	"-w":         "-w",
+	"--warning":  "-w",
+	"-c":         "-c",
+	"--critical": "-c",
+	"-d":         "-d",
+	"--display":  "-d",
+	"-u":         "-u",
+}
+
+// Options controls how check_nt invocations are rewritten.
+type Options struct {
+
+	// PluginName replaces the check_nt file name in command lines. Any
+	// directory before it (i.e. $USER1$/) is kept.
+	PluginName string
+
+	// Port, if set, replaces the port given to check_nt. Otherwise the port
+	// is removed, as it is normally the NSClient port rather than the
+	// monitoring-agent one, and the plugin falls back to its default port.
+	Port string
+
+	// SupportedVariables are the check_nt variables the plugin can check,
+	// anything else is reported as an Issue.
+	SupportedVariables []string
+}
+
+// File is the path and content of an object configuration file.
+type File struct {
+	Path    string
+	Content string
+}
+
+// Issue is a check_nt invocation which could not be translated.
+type Issue struct {
+	Path     string
+	Line     int
+	Object   string
+	Variable string
+	Reason   string
+}
+
+func (issue Issue) String() string {
+	if issue.Variable == "" {
+		return fmt.Sprintf("%s:%d: %s: %s", issue.Path, issue.Line, issue.Object, issue.Reason)
+	}
+	return fmt.Sprintf("%s:%d: %s: variable %s %s", issue.Path, issue.Line, issue.Object, issue.Variable, issue.Reason)
+}
+
+// Result holds the rewritten files, in the order they were given, along with
+// anything that could not be translated.
+type Result struct {
+	Files  []File
+	Issues []Issue
+}
+
+// arguments is what was found while translating the arguments of a check_nt
+// invocation.
+type arguments struct {
+	replacements map[int]string
+
+	// variable is the value given to -v, or an empty string if there is no
+	// -v flag.
+	variable string
+
+	// fragments are the numbers of the $ARGn$ macros which are used as
+	// arguments in their own right, rather than as the value of a flag.
+	fragments []int
+
+	unknownFlags []string
+}
+
+// translateArguments translates the check_nt arguments in tokens, starting
+// from the token at index from.
+func translateArguments(tokens []token, from int, options Options) arguments {
+	translated := arguments{replacements: map[int]string{}}
+
+	for i := from; i < len(tokens); i++ {
+		name := tokens[i].unquoted()
+		value := ""
+		hasValue := i+1 < len(tokens)
+		if hasValue {
+			value = tokens[i+1].unquoted()
+		}
+
+		// --name=value is kept as a single token.
+		inlineValue := false
+		if strings.HasPrefix(name, "--") && strings.Contains(name, "=") {
+			parts := strings.SplitN(name, "=", 2)
+			name, value, hasValue, inlineValue = parts[0], parts[1], true, true
+		}
+
+		replaceFlag := func(flagName string) {
+			if inlineValue {
+				translated.replacements[i] = fmt.Sprintf("%s=%s", flagName, tokens[i].text[strings.Index(tokens[i].text, "=")+1:])
+				return
+			}
+			if flagName != tokens[i].text {
+				translated.replacements[i] = flagName
+			}
+		}
+
+		switch {
+		case renamedFlags[name] == "-port" && options.Port == "":
+			translated.replacements[i] = ""
+			if hasValue && !inlineValue {
+				translated.replacements[i+1] = ""
+			}
+		case renamedFlags[name] != "":
+			replaceFlag(renamedFlags[name])
+			if renamedFlags[name] == "-port" && hasValue {
+				if inlineValue {
+					translated.replacements[i] = "-port=" + options.Port
+				} else {
+					translated.replacements[i+1] = options.Port
+				}
+			}
+		case timeoutFlags[name]:
+			if seconds, err := strconv.Atoi(value); err == nil && hasValue {
+				if inlineValue {
+					translated.replacements[i] = fmt.Sprintf("-timeout=%ds", seconds)
+				} else {
+					translated.replacements[i] = "-timeout"
+					translated.replacements[i+1] = fmt.Sprintf("%ds", seconds)
+				}
+			} else {
+				replaceFlag("-t")
+			}
+		case keptFlags[name] != "":
+			replaceFlag(keptFlags[name])
+			if keptFlags[name] == "-v" {
+				translated.variable = value
+			}
+		case argMacro.MatchString(name):
+			number, _ := strconv.Atoi(argMacro.FindStringSubmatch(name)[1])
+			translated.fragments = append(translated.fragments, number)
+			continue
+		case strings.HasPrefix(name, "-"):
+			translated.unknownFlags = append(translated.unknownFlags, name)
+			continue
+		default:
+			continue
+		}
+
+		if !inlineValue {
+			i++
+		}
+	}
+
+	return translated
+}
+
+// checkNtCommand is a command definition which invokes check_nt.
+type checkNtCommand struct {
+	definition definition
+	arguments  arguments
+}
+
+// splitCheckCommand splits a check_command on the unescaped ! characters
+// which separate the command name from its arguments.
+func splitCheckCommand(checkCommand string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(checkCommand); i++ {
+		if checkCommand[i] == '!' && (i == 0 || checkCommand[i-1] != '\\') {
+			parts = append(parts, checkCommand[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, checkCommand[start:])
+}
+
+type migration struct {
+	options  Options
+	issues   []Issue
+	commands map[string]*checkNtCommand
+}
+
+func (m *migration) addIssue(d definition, directiveName string, variable string, reason string) {
+	m.issues = append(m.issues, Issue{
+		Path:     d.file.path,
+		Line:     d.directives[directiveName].line + 1,
+		Object:   d.String(),
+		Variable: variable,
+		Reason:   reason,
+	})
+}
+
+func (m *migration) isSupported(variable string) bool {
+	for _, supported := range m.options.SupportedVariables {
+		if strings.EqualFold(supported, variable) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVariable records an issue if a resolved variable is not supported or
+// could not be resolved.
+func (m *migration) checkVariable(d definition, directiveName string, variable string) {
+	switch {
+	case variable == "":
+		m.addIssue(d, directiveName, "", "check_nt is invoked without a variable")
+	case strings.HasPrefix(variable, "$"):
+		m.addIssue(d, directiveName, variable, "is set by a macro which cannot be resolved")
+	case !m.isSupported(variable):
+		m.addIssue(d, directiveName, strings.ToUpper(variable), "is not supported")
+	}
+}
+
+// rewriteCommand rewrites a command definition if it invokes check_nt.
+func (m *migration) rewriteCommand(d definition) {
+	commandLine := d.value("command_line")
+	tokens := tokenize(commandLine)
+
+	for i, t := range tokens {
+		if path.Base(t.unquoted()) != checkNtName {
+			continue
+		}
+
+		translated := translateArguments(tokens, i+1, m.options)
+		nameStart := strings.LastIndex(t.text, checkNtName)
+		translated.replacements[i] = t.text[:nameStart] + m.options.PluginName + t.text[nameStart+len(checkNtName):]
+		d.setValue("command_line", replaceTokens(commandLine, tokens, translated.replacements))
+
+		for _, flagName := range translated.unknownFlags {
+			m.addIssue(d, "command_line", "", fmt.Sprintf("unknown check_nt argument %s", flagName))
+		}
+
+		m.commands[d.value("command_name")] = &checkNtCommand{
+			definition: d,
+			arguments:  translated,
+		}
+
+		if translated.variable != "" && !argMacro.MatchString(translated.variable) {
+			m.checkVariable(d, "command_line", translated.variable)
+		}
+		return
+	}
+}
+
+// rewriteCheckCommand rewrites the arguments of a check_command which uses
+// a check_nt command and checks the variable it resolves to.
+func (m *migration) rewriteCheckCommand(d definition) {
+	parts := splitCheckCommand(d.value("check_command"))
+	command, ok := m.commands[strings.TrimSpace(parts[0])]
+	if !ok {
+		return
+	}
+
+	argument := func(number int) string {
+		if number < len(parts) {
+			return parts[number]
+		}
+		return ""
+	}
+
+	variable := command.arguments.variable
+	if match := argMacro.FindStringSubmatch(variable); match != nil {
+		number, _ := strconv.Atoi(match[1])
+		variable = strings.Trim(strings.TrimSpace(argument(number)), `"'`)
+	}
+
+	for _, number := range command.arguments.fragments {
+		if number >= len(parts) {
+			continue
+		}
+		tokens := tokenize(parts[number])
+		translated := translateArguments(tokens, 0, m.options)
+		parts[number] = replaceTokens(parts[number], tokens, translated.replacements)
+
+		for _, flagName := range translated.unknownFlags {
+			m.addIssue(d, "check_command", "", fmt.Sprintf("unknown check_nt argument %s", flagName))
+		}
+		if command.arguments.variable == "" && translated.variable != "" {
+			variable = translated.variable
+		}
+	}
+
+	d.setValue("check_command", strings.Join(parts, "!"))
+
+	if command.arguments.variable == "" || argMacro.MatchString(command.arguments.variable) {
+		m.checkVariable(d, "check_command", variable)
+	}
+}
+
+// Migrate rewrites every check_nt invocation within the given object
+// configuration files. All files making up a configuration should be given
+// at once, so that commands can be followed from the services which use
+// them.
+func Migrate(files []File, options Options) Result {
+	m := migration{
+		options:  options,
+		commands: map[string]*checkNtCommand{},
+	}
+
+	objectFiles := make([]*objectFile, len(files))
+	var definitions []definition
+	for i, file := range files {
+		objectFiles[i] = &objectFile{
+			path:  file.Path,
+			lines: strings.Split(file.Content, "\n"),
+		}
+		definitions = append(definitions, parseDefinitions(objectFiles[i])...)
+	}
+
+	for _, d := range definitions {
+		if d.objectType == "command" {
+			m.rewriteCommand(d)
+		}
+	}
+
+	for _, d := range definitions {
+		if _, ok := d.directives["check_command"]; ok {
+			m.rewriteCheckCommand(d)
+		}
+	}
+
+	result := Result{Issues: m.issues}
+	for _, file := range objectFiles {
+		result.Files = append(result.Files, File{
+			Path:    file.path,
+			Content: strings.Join(file.lines, "\n"),
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testOptions = Options{
+	PluginName:         "monitoring-agent-check-nt-replacement",
+	SupportedVariables: []string{"COUNTER", "CPULOAD", "MEMUSE"},
+}
+
+func TestMigrate(t *testing.T) {
+	t.Run("Command lines with literal arguments are rewritten", func(t *testing.T) {
+		result := Migrate([]File{{
+			Path: "commands.cfg",
+			Content: `define command {
+    command_name    check_nt_memuse
+    command_line    $USER1$/check_nt -H $HOSTADDRESS$ -p 12489 -s secret -t 10 -v MEMUSE -w 80 -c 90 ; memory
+}
+`,
+		}}, testOptions)
+
+		assert.Equal(t, `define command {
+    command_name    check_nt_memuse
+    command_line    $USER1$/monitoring-agent-check-nt-replacement -host $HOSTADDRESS$ -password secret -timeout 10s -v MEMUSE -w 80 -c 90 ; memory
+}
+`, result.Files[0].Content)
+		assert.Empty(t, result.Issues)
+	})
+
+	t.Run("Variables are resolved through $ARGn$ macros across files", func(t *testing.T) {
+		result := Migrate([]File{
+			{
+				Path: "commands.cfg",
+				Content: `define command{
+	command_name	check_nt
+	command_line	$USER1$/check_nt -H $HOSTADDRESS$ -v $ARG1$ $ARG2$
+	}`,
+			},
+			{
+				Path: "services.cfg",
+				Content: `define service{
+	host_name		web01
+	service_description	CPU
+	check_command		check_nt!CPULOAD!-l 5,80,90 -s secret
+	}
+define service{
+	host_name		web01
+	service_description	W3SVC
+	check_command		check_nt!SERVICESTATE!-d SHOWALL -l W3SVC
+	}`,
+			},
+		}, testOptions)
+
+		assert.Equal(t, "\tcommand_line\t$USER1$/monitoring-agent-check-nt-replacement -host $HOSTADDRESS$ -v $ARG1$ $ARG2$", splitLines(result.Files[0].Content)[2])
+		assert.Equal(t, "\tcheck_command\t\tcheck_nt!CPULOAD!-l 5,80,90 -password secret", splitLines(result.Files[1].Content)[3])
+		assert.Equal(t, []Issue{{
+			Path:     "services.cfg",
+			Line:     9,
+			Object:   `service "web01/W3SVC"`,
+			Variable: "SERVICESTATE",
+			Reason:   "is not supported",
+		}}, result.Issues)
+	})
+
+	t.Run("Variables given within an argument fragment are found", func(t *testing.T) {
+		result := Migrate([]File{{
+			Path: "objects.cfg",
+			Content: `define command {
+    command_name    check_nt_generic
+    command_line    "/usr/lib/nagios/plugins/check_nt" -H $HOSTADDRESS$ $ARG1$
+}
+define host {
+    host_name       db01
+    check_command   check_nt_generic!--variable=FILEAGE -l "C:\\file.txt"
+}
+`,
+		}}, testOptions)
+
+		assert.Equal(t, `    command_line    "/usr/lib/nagios/plugins/monitoring-agent-check-nt-replacement" -host $HOSTADDRESS$ $ARG1$`, splitLines(result.Files[0].Content)[2])
+		assert.Equal(t, `    check_command   check_nt_generic!-v=FILEAGE -l "C:\\file.txt"`, splitLines(result.Files[0].Content)[6])
+		assert.Len(t, result.Issues, 1)
+		assert.Equal(t, "objects.cfg:7: host \"db01\": variable FILEAGE is not supported", result.Issues[0].String())
+	})
+
+	t.Run("Unsupported literal variables and unknown flags are reported on the command", func(t *testing.T) {
+		result := Migrate([]File{{
+			Path: "commands.cfg",
+			Content: `define command {
+    command_name    check_nt_version
+    command_line    $USER1$/check_nt -H $HOSTADDRESS$ -v CLIENTVERSION -X
+}
+`,
+		}}, testOptions)
+
+		assert.Equal(t, []string{
+			"commands.cfg:3: command \"check_nt_version\": unknown check_nt argument -X",
+			"commands.cfg:3: command \"check_nt_version\": variable CLIENTVERSION is not supported",
+		}, issueStrings(result.Issues))
+	})
+
+	t.Run("The port is replaced if requested", func(t *testing.T) {
+		options := testOptions
+		options.Port = "9000"
+		result := Migrate([]File{{
+			Path:    "commands.cfg",
+			Content: "define command {\n command_name check_nt\n command_line check_nt -H $HOSTADDRESS$ --port=12489 $ARG1$\n}",
+		}}, options)
+
+		assert.Equal(t, " command_line monitoring-agent-check-nt-replacement -host $HOSTADDRESS$ -port=9000 $ARG1$", splitLines(result.Files[0].Content)[2])
+	})
+
+	t.Run("The NSClient port is removed unless a port is requested", func(t *testing.T) {
+		result := Migrate([]File{{
+			Path:    "commands.cfg",
+			Content: "define command {\n command_name check_nt\n command_line check_nt -H $HOSTADDRESS$ --port=12489 -p $ARG2$ -v $ARG1$\n}",
+		}}, testOptions)
+
+		assert.Equal(t, " command_line monitoring-agent-check-nt-replacement -host $HOSTADDRESS$ -v $ARG1$", splitLines(result.Files[0].Content)[2])
+	})
+
+	t.Run("Commands which do not use check_nt are left alone", func(t *testing.T) {
+		content := `# check_nt is not used here
+define command {
+    command_name    check_ping
+    command_line    $USER1$/check_ping -H $HOSTADDRESS$ -w 100,20% -c 500,60%
+}
+define service {
+    host_name             web01
+    service_description   PING
+    check_command         check_ping
+}
+`
+		result := Migrate([]File{{Path: "commands.cfg", Content: content}}, testOptions)
+
+		assert.Equal(t, content, result.Files[0].Content)
+		assert.Empty(t, result.Issues)
+	})
+}
+
+func splitLines(content string) []string {
+	return strings.Split(content, "\n")
+}
+
+func issueStrings(issues []Issue) []string {
+	var output []string
+	for _, issue := range issues {
+		output = append(output, issue.String())
+	}
+	return output
+}
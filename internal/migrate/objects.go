@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	defineStart   = regexp.MustCompile(`^\s*define\s+(\w+)\s*\{`)
+	defineEnd     = regexp.MustCompile(`^\s*\}`)
+	directiveLine = regexp.MustCompile(`^(\s*)(\S+)(\s+)`)
+)
+
+// directive is a single "name value" line of an object definition.
+type directive struct {
+	name string
+
+	// line is the index of the line within the file.
+	line int
+
+	// valueStart and valueEnd are the offsets of the value within the line,
+	// which excludes any trailing comment and whitespace.
+	valueStart int
+	valueEnd   int
+}
+
+// definition is a single "define type { ... }" object definition.
+type definition struct {
+	file       *objectFile
+	line       int
+	objectType string
+	directives map[string]directive
+}
+
+// value returns the value of a directive, or an empty string if the
+// definition does not have it.
+func (d definition) value(name string) string {
+	found, ok := d.directives[name]
+	if !ok {
+		return ""
+	}
+	return d.file.lines[found.line][found.valueStart:found.valueEnd]
+}
+
+// setValue replaces the value of a directive.
+func (d definition) setValue(name string, value string) {
+	found := d.directives[name]
+	line := d.file.lines[found.line]
+	d.file.lines[found.line] = line[:found.valueStart] + value + line[found.valueEnd:]
+}
+
+// String describes the definition for use in reports, i.e.
+// service "web01/C Drive".
+func (d definition) String() string {
+	switch d.objectType {
+	case "service":
+		return fmt.Sprintf("service %q", d.value("host_name")+"/"+d.value("service_description"))
+	default:
+		if name := d.value(d.objectType + "_name"); name != "" {
+			return fmt.Sprintf("%s %q", d.objectType, name)
+		}
+		return fmt.Sprintf("%s %q", d.objectType, d.value("name"))
+	}
+}
+
+// objectFile is an object configuration file split into lines, which are
+// updated in place as definitions are rewritten.
+type objectFile struct {
+	path  string
+	lines []string
+}
+
+// valueEnd returns the offset at which the value of a directive ends, which
+// is the first unescaped semicolon (the start of a comment) with any
+// whitespace before it removed.
+func valueEnd(line string, valueStart int) int {
+	end := len(line)
+	for i := valueStart; i < len(line); i++ {
+		if line[i] == ';' && (i == 0 || line[i-1] != '\\') {
+			end = i
+			break
+		}
+	}
+	return valueStart + len(strings.TrimRight(line[valueStart:end], " \t\r"))
+}
+
+// parseDefinitions finds the object definitions within a file.
+func parseDefinitions(file *objectFile) []definition {
+	var definitions []definition
+	var current *definition
+
+	for i, line := range file.lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+
+		if current == nil {
+			if match := defineStart.FindStringSubmatch(line); match != nil {
+				current = &definition{
+					file:       file,
+					line:       i,
+					objectType: match[1],
+					directives: map[string]directive{},
+				}
+			}
+			continue
+		}
+
+		if defineEnd.MatchString(line) {
+			definitions = append(definitions, *current)
+			current = nil
+			continue
+		}
+
+		if match := directiveLine.FindStringSubmatchIndex(line); match != nil {
+			name := line[match[4]:match[5]]
+			valueStart := match[1]
+			current.directives[name] = directive{
+				name:       name,
+				line:       i,
+				valueStart: valueStart,
+				valueEnd:   valueEnd(line, valueStart),
+			}
+		}
+	}
+
+	return definitions
+}
@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"strings"
+)
+
+// token is a single shell word of a command line, along with its position
+// so that it can be replaced without disturbing the rest of the line.
+type token struct {
+	start int
+	end   int
+	text  string
+}
+
+// unquoted returns the token with any quoting removed.
+func (t token) unquoted() string {
+	var output strings.Builder
+	var quote byte
+
+	for i := 0; i < len(t.text); i++ {
+		character := t.text[i]
+		switch {
+		case quote == 0 && (character == '"' || character == '\''):
+			quote = character
+		case quote != 0 && character == quote:
+			quote = 0
+		default:
+			output.WriteByte(character)
+		}
+	}
+
+	return output.String()
+}
+
+// tokenize splits a command line into shell words, keeping quoted sections
+// together.
+func tokenize(commandLine string) []token {
+	var tokens []token
+	var quote byte
+	start := -1
+
+	for i := 0; i < len(commandLine); i++ {
+		character := commandLine[i]
+
+		if quote != 0 {
+			if character == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch character {
+		case ' ', '\t':
+			if start >= 0 {
+				tokens = append(tokens, token{start: start, end: i, text: commandLine[start:i]})
+				start = -1
+			}
+		case '"', '\'':
+			quote = character
+			if start < 0 {
+				start = i
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(commandLine), text: commandLine[start:]})
+	}
+
+	return tokens
+}
+
+// replaceTokens rebuilds a command line with some of its tokens replaced. An
+// empty replacement removes the token along with the whitespace before it.
+func replaceTokens(commandLine string, tokens []token, replacements map[int]string) string {
+	var output strings.Builder
+	position := 0
+
+	for i, t := range tokens {
+		replacement, ok := replacements[i]
+		if !ok {
+			continue
+		}
+		before := commandLine[position:t.start]
+		if replacement == "" {
+			before = strings.TrimRight(before, " \t")
+		}
+		output.WriteString(before)
+		output.WriteString(replacement)
+		position = t.end
+	}
+	output.WriteString(commandLine[position:])
+
+	return output.String()
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"regexp"
 	"runtime/debug"
@@ -71,6 +72,10 @@ const (
 	defaultTimeMetricUnitOfMeasurement string = "ms"
 )
 
+// UndeterminedValue is the performance data value used when the actual
+// value could not be determined.
+const UndeterminedValue string = "U"
+
 // Sentinel error collection. Exported for potential use by client code to
 // detect & handle specific error scenarios.
 var (
@@ -93,8 +98,55 @@ var (
 	// ErrNoPerformanceDataProvided indicates that client code did not provide
 	// the expected PerformanceData value(s).
 	ErrNoPerformanceDataProvided = errors.New("no performance data provided")
+
+	// ErrInvalidServiceState indicates that client code provided a state
+	// which is not one of OK, WARNING, CRITICAL or UNKNOWN.
+	ErrInvalidServiceState = errors.New("invalid service state")
+
+	// ErrPerformanceDataUndetermined indicates that a PerformanceData value
+	// is the UndeterminedValue, so it cannot be compared to thresholds.
+	ErrPerformanceDataUndetermined = errors.New("performance data value is undetermined")
+
+	// ErrPerformanceDataNotNumeric indicates that a PerformanceData value is
+	// neither a number nor the UndeterminedValue.
+	ErrPerformanceDataNotNumeric = errors.New("performance data value is not numeric")
+
+	// ErrPerformanceDataCollision indicates that client code provided a
+	// PerformanceData value with a label which is already in use while the
+	// PerfDataCollisionError policy is set.
+	ErrPerformanceDataCollision = errors.New("performance data label already in use")
+
+	// ErrInvalidPerfDataCollisionPolicy indicates that client code provided
+	// a collision policy name which is not one of error, suffix or keep-last.
+	ErrInvalidPerfDataCollisionPolicy = errors.New("invalid performance data collision policy")
+)
+
+// PerfDataCollisionPolicy decides what AddPerfData does with a
+// PerformanceData value whose label, ignoring case, is already in use.
+type PerfDataCollisionPolicy int
+
+const (
+	// PerfDataCollisionKeepLast replaces the earlier value with the later
+	// one. This is the default.
+	PerfDataCollisionKeepLast PerfDataCollisionPolicy = iota
+
+	// PerfDataCollisionError rejects the later value with
+	// ErrPerformanceDataCollision.
+	PerfDataCollisionError
+
+	// PerfDataCollisionSuffix keeps both values by appending _2, _3 and so
+	// on to the label of the later one.
+	PerfDataCollisionSuffix
 )
 
+// perfDataCollisionPolicyNames are the names accepted by
+// ParsePerfDataCollisionPolicy.
+var perfDataCollisionPolicyNames = map[string]PerfDataCollisionPolicy{
+	"keep-last": PerfDataCollisionKeepLast,
+	"error":     PerfDataCollisionError,
+	"suffix":    PerfDataCollisionSuffix,
+}
+
 // ServiceState represents the status label and exit code for a service check.
 type ServiceState struct {
 
@@ -286,6 +338,48 @@ func ParseRangeString(input string) *Range {
 
 }
 
+// Scale returns a copy of the range with both of its ends multiplied by
+// factor, which is expected to be positive. It is used to express a range
+// given in one unit (i.e. a percentage) in another (i.e. bytes). The ends are
+// rounded to 12 significant digits, so that the error of the multiplication
+// (i.e. 1.0000000000000002) does not show when the range is printed.
+func (r Range) Scale(factor float64) Range {
+	r.Start = scaleRangeBound(r.Start, factor)
+	r.End = scaleRangeBound(r.End, factor)
+	return r
+}
+
+// scaleRangeBound multiplies one end of a range by factor, rounded to 12
+// significant digits.
+func scaleRangeBound(bound float64, factor float64) float64 {
+	scaled, _ := strconv.ParseFloat(strconv.FormatFloat(bound*factor, 'g', 12, 64), 64)
+	return scaled
+}
+
+// String returns the range in the threshold format understood by
+// ParseRangeString.
+func (r Range) String() string {
+	var output strings.Builder
+
+	if r.AlertOn == "INSIDE" {
+		output.WriteString("@")
+	}
+
+	switch {
+	case r.Start_Infinity:
+		output.WriteString("~:")
+	case r.Start != 0 || r.End_Infinity:
+		output.WriteString(strconv.FormatFloat(r.Start, 'f', -1, 64))
+		output.WriteString(":")
+	}
+
+	if !r.End_Infinity {
+		output.WriteString(strconv.FormatFloat(r.End, 'f', -1, 64))
+	}
+
+	return output.String()
+}
+
 // Validate performs basic validation of PerformanceData. An error is returned
 // for any validation failures.
 func (pd PerformanceData) Validate() error {
@@ -377,6 +471,10 @@ type Plugin struct {
 	// generated by the plugin. Each entry in the collection is unique.
 	perfData map[string]PerformanceData
 
+	// perfDataCollisionPolicy decides what happens when performance data is
+	// added with a label which is already in use.
+	perfDataCollisionPolicy PerfDataCollisionPolicy
+
 	// WarningThreshold is the value used to determine when the service check
 	// has crossed between an existing state into a WARNING state. This value
 	// is used for display purposes.
@@ -387,6 +485,11 @@ type Plugin struct {
 	// is used for display purposes.
 	CriticalThreshold string
 
+	// ThresholdDetails are additional entries listed in the Thresholds
+	// section, such as thresholds which only apply to some of the
+	// performance data.
+	ThresholdDetails []string
+
 	// thresholdLabel is an optional custom label used in place of the
 	// standard text prior to a list of threshold values.
 	thresholdsLabel string
@@ -567,23 +670,79 @@ func (p *Plugin) AddPerfData(skipValidate bool, perfData ...PerformanceData) err
 		p.perfData = make(map[string]PerformanceData)
 	}
 
+	if p.perfDataCollisionPolicy == PerfDataCollisionError {
+		seen := make(map[string]bool, len(perfData))
+		for _, pd := range perfData {
+			key := strings.ToLower(pd.Label)
+			if _, exists := p.perfData[key]; exists || seen[key] {
+				return fmt.Errorf("%w: %q", ErrPerformanceDataCollision, pd.Label)
+			}
+			seen[key] = true
+		}
+	}
+
 	for _, pd := range perfData {
+		if p.perfDataCollisionPolicy == PerfDataCollisionSuffix {
+			pd.Label = p.unusedPerfDataLabel(pd.Label)
+		}
 		p.perfData[strings.ToLower(pd.Label)] = pd
 	}
 
 	return nil
 }
 
+// unusedPerfDataLabel returns the label, or the label with the first of _2,
+// _3 and so on appended which is not in use yet.
+func (p *Plugin) unusedPerfDataLabel(label string) string {
+	candidate := label
+	for suffix := 2; ; suffix++ {
+		if _, exists := p.perfData[strings.ToLower(candidate)]; !exists {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d", label, suffix)
+	}
+}
+
+// SetPerfDataCollisionPolicy sets what AddPerfData does with performance
+// data whose label is already in use.
+func (p *Plugin) SetPerfDataCollisionPolicy(policy PerfDataCollisionPolicy) {
+	p.perfDataCollisionPolicy = policy
+}
+
+// ParsePerfDataCollisionPolicy returns the policy named error, suffix or
+// keep-last, ignoring case.
+func ParsePerfDataCollisionPolicy(input string) (PerfDataCollisionPolicy, error) {
+	policy, ok := perfDataCollisionPolicyNames[strings.ToLower(input)]
+	if !ok {
+		return PerfDataCollisionKeepLast, fmt.Errorf("%w: %q", ErrInvalidPerfDataCollisionPolicy, input)
+	}
+	return policy, nil
+}
+
+// EvaluateThreshold raises the exit status of the plugin to CRITICAL or
+// WARNING if any of the provided performance data values is outside of its
+// thresholds. The exit status is never lowered, so the worst state across
+// multiple calls is kept. Values which are undetermined or not numeric are
+// not evaluated, the first of them is returned as an error.
 func (p *Plugin) EvaluateThreshold(perfData ...PerformanceData) error {
+	var valueErr error
+
 	for i := range perfData {
 
+		if _, err := ParsePerfDataValue(perfData[i].Value); err != nil {
+			if valueErr == nil {
+				valueErr = fmt.Errorf("%s: %w", perfData[i].Label, err)
+			}
+			continue
+		}
+
 		if perfData[i].Crit != "" {
 
 			CriticalThresholdObject := ParseRangeString(perfData[i].Crit)
 
 			if CriticalThresholdObject.CheckRange(perfData[i].Value) {
-				p.ExitStatusCode = StateCRITICALExitCode
-				return nil
+				p.RaiseExitStatusCode(StateCRITICALExitCode)
+				continue
 			}
 		}
 
@@ -591,13 +750,69 @@ func (p *Plugin) EvaluateThreshold(perfData ...PerformanceData) error {
 			warningThresholdObject := ParseRangeString(perfData[i].Warn)
 
 			if warningThresholdObject.CheckRange(perfData[i].Value) {
-				p.ExitStatusCode = StateWARNINGExitCode
-				return nil
+				p.RaiseExitStatusCode(StateWARNINGExitCode)
 			}
 		}
 	}
 
-	return nil
+	return valueErr
+}
+
+// ParsePerfDataValue returns a performance data value as a number, or
+// ErrPerformanceDataUndetermined or ErrPerformanceDataNotNumeric if it
+// cannot be compared to thresholds. NaN is not numeric.
+func ParsePerfDataValue(value string) (float64, error) {
+	if value == UndeterminedValue {
+		return 0, ErrPerformanceDataUndetermined
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) {
+		return 0, fmt.Errorf("%w: %q", ErrPerformanceDataNotNumeric, value)
+	}
+	return number, nil
+}
+
+// stateSeverity orders exit codes from the least to the most severe, in the
+// same way as max_state_alt from the Monitoring Plugins project.
+var stateSeverity = map[int]int{
+	StateOKExitCode:        0,
+	StateDEPENDENTExitCode: 1,
+	StateUNKNOWNExitCode:   2,
+	StateWARNINGExitCode:   3,
+	StateCRITICALExitCode:  4,
+}
+
+// RaiseExitStatusCode sets the exit status code if it is more severe than
+// the current one, so that the worst state is kept.
+func (p *Plugin) RaiseExitStatusCode(exitCode int) {
+	if stateSeverity[exitCode] > stateSeverity[p.ExitStatusCode] {
+		p.ExitStatusCode = exitCode
+	}
+}
+
+// ParseServiceState returns the ServiceState for a state label (i.e.
+// "critical") or exit code (i.e. "2"), ignoring case.
+func ParseServiceState(input string) (ServiceState, error) {
+	states := []ServiceState{
+		{Label: StateOKLabel, ExitCode: StateOKExitCode},
+		{Label: StateWARNINGLabel, ExitCode: StateWARNINGExitCode},
+		{Label: StateCRITICALLabel, ExitCode: StateCRITICALExitCode},
+		{Label: StateUNKNOWNLabel, ExitCode: StateUNKNOWNExitCode},
+	}
+
+	for _, state := range states {
+		if strings.EqualFold(input, state.Label) || input == strconv.Itoa(state.ExitCode) {
+			return state, nil
+		}
+	}
+
+	return ServiceState{}, fmt.Errorf("%w: %q", ErrInvalidServiceState, input)
+}
+
+// AddThresholdDetail appends provided entries to the Thresholds section.
+func (p *Plugin) AddThresholdDetail(detail ...string) {
+	p.ThresholdDetails = append(p.ThresholdDetails, detail...)
 }
 
 // AddError appends provided errors to the collection.
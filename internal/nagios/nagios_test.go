@@ -1,6 +1,7 @@
 package nagios
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -189,4 +190,159 @@ func TestThis(t *testing.T) {
 
 		assert.Equal(t, StateCRITICALExitCode, plugin.ExitStatusCode)
 	})
+
+	t.Run("Plugin should not lower a CRITICAL exit code when a later value is only within warning range", func(t *testing.T) {
+		var plugin = Plugin{
+			ExitStatusCode: StateOKExitCode,
+		}
+		plugin.ServiceOutput = "CHECK-NT-REPLACEMENT"
+
+		critical := PerformanceData{
+			Label: "first",
+			Value: "41.0",
+			Warn:  "5:30",
+			Crit:  "0:40",
+		}
+		warning := PerformanceData{
+			Label: "second",
+			Value: "31.0",
+			Warn:  "5:30",
+			Crit:  "0:40",
+		}
+		plugin.EvaluateThreshold(critical)
+		plugin.EvaluateThreshold(warning)
+
+		assert.Equal(t, StateCRITICALExitCode, plugin.ExitStatusCode)
+	})
+
+	t.Run("Plugin should evaluate every value passed to EvaluateThreshold", func(t *testing.T) {
+		var plugin = Plugin{
+			ExitStatusCode: StateOKExitCode,
+		}
+
+		warning := PerformanceData{
+			Label: "first",
+			Value: "31.0",
+			Warn:  "5:30",
+			Crit:  "0:40",
+		}
+		critical := PerformanceData{
+			Label: "second",
+			Value: "41.0",
+			Warn:  "5:30",
+			Crit:  "0:40",
+		}
+		plugin.EvaluateThreshold(warning, critical)
+
+		assert.Equal(t, StateCRITICALExitCode, plugin.ExitStatusCode)
+	})
+
+	t.Run("Ranges should format back into the threshold format", func(t *testing.T) {
+		for _, input := range []string{"10", "10:", "~:30", "5:33", "@32:64", "@32", "0:"} {
+			assert.Equal(t, input, ParseRangeString(input).String())
+		}
+	})
+
+	t.Run("Scaling a range should scale both ends", func(t *testing.T) {
+		assert.Equal(t, "800:900", ParseRangeString("80:90").Scale(10).String())
+		assert.Equal(t, "@~:5", ParseRangeString("@~:50").Scale(0.1).String())
+		assert.Equal(t, "20:", ParseRangeString("10:").Scale(2).String())
+		assert.Equal(t, "0.3:0.7", ParseRangeString("3:7").Scale(0.1).String())
+		assert.Equal(t, "@85899345920:", ParseRangeString("@80:").Scale(1073741824).String())
+	})
+
+	t.Run("Raising the exit status code should keep the most severe state", func(t *testing.T) {
+		var plugin = Plugin{
+			ExitStatusCode: StateOKExitCode,
+		}
+
+		plugin.RaiseExitStatusCode(StateUNKNOWNExitCode)
+		assert.Equal(t, StateUNKNOWNExitCode, plugin.ExitStatusCode)
+
+		plugin.RaiseExitStatusCode(StateWARNINGExitCode)
+		assert.Equal(t, StateWARNINGExitCode, plugin.ExitStatusCode)
+
+		plugin.RaiseExitStatusCode(StateOKExitCode)
+		assert.Equal(t, StateWARNINGExitCode, plugin.ExitStatusCode)
+	})
+
+	t.Run("Service states should parse from labels and exit codes", func(t *testing.T) {
+		state, err := ParseServiceState("critical")
+		assert.Nil(t, err)
+		assert.Equal(t, ServiceState{Label: StateCRITICALLabel, ExitCode: StateCRITICALExitCode}, state)
+
+		state, err = ParseServiceState("3")
+		assert.Nil(t, err)
+		assert.Equal(t, StateUNKNOWNLabel, state.Label)
+
+		_, err = ParseServiceState("bad")
+		assert.ErrorIs(t, err, ErrInvalidServiceState)
+	})
+
+	t.Run("Threshold details should be listed in the thresholds section", func(t *testing.T) {
+		var plugin = Plugin{
+			LongServiceOutput: "c:: 15%",
+		}
+		plugin.AddThresholdDetail("c:: CRITICAL 10: (c:), WARNING 20: (*)")
+
+		var output strings.Builder
+		plugin.handleThresholdsSection(&output)
+		assert.Contains(t, output.String(), "* c:: CRITICAL 10: (c:), WARNING 20: (*)")
+	})
+
+	t.Run("Colliding performance data labels should follow the collision policy", func(t *testing.T) {
+		first := PerformanceData{Label: "disk", Value: "1"}
+		second := PerformanceData{Label: "Disk", Value: "2"}
+
+		var plugin Plugin
+		assert.Nil(t, plugin.AddPerfData(false, first, second))
+		assert.Equal(t, []PerformanceData{second}, plugin.getSortedPerfData())
+
+		plugin = Plugin{}
+		plugin.SetPerfDataCollisionPolicy(PerfDataCollisionError)
+		assert.Nil(t, plugin.AddPerfData(false, first))
+		assert.ErrorIs(t, plugin.AddPerfData(false, second), ErrPerformanceDataCollision)
+		assert.Equal(t, []PerformanceData{first}, plugin.getSortedPerfData())
+
+		plugin = Plugin{}
+		plugin.SetPerfDataCollisionPolicy(PerfDataCollisionSuffix)
+		assert.Nil(t, plugin.AddPerfData(false, first, second, second))
+		labels := []string{}
+		for _, pd := range plugin.getSortedPerfData() {
+			labels = append(labels, pd.Label)
+		}
+		assert.Equal(t, []string{"disk", "Disk_2", "Disk_3"}, labels)
+	})
+
+	t.Run("Collision policies should parse from their names", func(t *testing.T) {
+		policy, err := ParsePerfDataCollisionPolicy("Suffix")
+		assert.Nil(t, err)
+		assert.Equal(t, PerfDataCollisionSuffix, policy)
+
+		_, err = ParsePerfDataCollisionPolicy("drop")
+		assert.ErrorIs(t, err, ErrInvalidPerfDataCollisionPolicy)
+	})
+
+	t.Run("Values which are undetermined or not numeric should not be evaluated", func(t *testing.T) {
+		var plugin = Plugin{
+			ExitStatusCode: StateOKExitCode,
+		}
+
+		err := plugin.EvaluateThreshold(
+			PerformanceData{Label: "a", Value: "abc", Crit: "10"},
+			PerformanceData{Label: "b", Value: UndeterminedValue, Crit: "10"},
+		)
+		assert.ErrorIs(t, err, ErrPerformanceDataNotNumeric)
+		assert.Equal(t, StateOKExitCode, plugin.ExitStatusCode)
+
+		err = plugin.EvaluateThreshold(PerformanceData{Label: "b", Value: UndeterminedValue, Crit: "10"})
+		assert.ErrorIs(t, err, ErrPerformanceDataUndetermined)
+
+		_, err = ParsePerfDataValue("NaN")
+		assert.ErrorIs(t, err, ErrPerformanceDataNotNumeric)
+
+		value, err := ParsePerfDataValue("12.5")
+		assert.Nil(t, err)
+		assert.Equal(t, 12.5, value)
+	})
 }
@@ -105,6 +105,10 @@ func (p Plugin) handleThresholdsSection(w io.Writer) {
 					CheckOutputEOL,
 				)
 			}
+
+			for _, detail := range p.ThresholdDetails {
+				fmt.Fprintf(w, "* %s%s", detail, CheckOutputEOL)
+			}
 		}
 	}
 
@@ -192,7 +196,7 @@ func (p *Plugin) handlePerformanceData(w io.Writer) {
 // isThresholdsSectionHidden indicates whether the Thresholds section should
 // be omitted from output.
 func (p Plugin) isThresholdsSectionHidden() bool {
-	if p.hideThresholdsSection || (p.WarningThreshold == "" && p.CriticalThreshold == "") {
+	if p.hideThresholdsSection || (p.WarningThreshold == "" && p.CriticalThreshold == "" && len(p.ThresholdDetails) == 0) {
 		return true
 	}
 	return false
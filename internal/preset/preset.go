@@ -0,0 +1,125 @@
+/*
+Package preset holds the catalog of ready made counter checks selected with
+-preset, such as cpu or disk-latency.
+
+Each preset bundles the counter paths, label template, unit, thresholds and
+instance filters of a check. The built-in presets are embedded in the
+binary and more can be loaded from a YAML file in the same form, where a
+preset with the name of a built-in one replaces it.
+*/
+package preset
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrUnknownPreset indicates that no preset has the requested name.
+	ErrUnknownPreset = errors.New("unknown preset")
+
+	// ErrInvalidPreset indicates that a catalog could not be parsed or
+	// contains a preset which cannot be used.
+	ErrInvalidPreset = errors.New("invalid preset")
+)
+
+//go:embed builtin.yaml
+var builtinCatalog []byte
+
+// Preset is a ready made counter check. Each field is named after the flag
+// it sets.
+type Preset struct {
+	Description      string   `yaml:"description"`
+	Counters         []string `yaml:"counters"`
+	Label            string   `yaml:"label"`
+	Unit             *string  `yaml:"unit"`
+	UnitFrom         string   `yaml:"unit-from"`
+	UnitTo           string   `yaml:"unit-to"`
+	Warning          string   `yaml:"warning"`
+	Critical         string   `yaml:"critical"`
+	IncludeInstances []string `yaml:"include-instance"`
+	ExcludeInstances []string `yaml:"exclude-instance"`
+	Aggregate        string   `yaml:"aggregate"`
+	Rate             bool     `yaml:"rate"`
+}
+
+// Catalog is a set of presets by their lower-cased name.
+type Catalog map[string]Preset
+
+// Builtin returns the presets embedded in the binary.
+func Builtin() Catalog {
+	catalog, err := Parse(builtinCatalog)
+	if err != nil {
+		panic(fmt.Errorf("error parsing the built-in presets %s", err.Error()))
+	}
+	return catalog
+}
+
+// Parse reads a catalog of presets from YAML. Unknown fields are rejected so
+// that misspelled fields are not silently ignored.
+func Parse(content []byte) (Catalog, error) {
+	var presets map[string]Preset
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&presets); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPreset, err.Error())
+	}
+
+	catalog := make(Catalog, len(presets))
+	for name, preset := range presets {
+		if len(preset.Counters) == 0 {
+			return nil, fmt.Errorf("%w: %s has no counters", ErrInvalidPreset, name)
+		}
+		catalog[strings.ToLower(name)] = preset
+	}
+	return catalog, nil
+}
+
+// LoadFile reads a catalog of presets from a YAML file.
+func LoadFile(path string) (Catalog, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content)
+}
+
+// Merge returns a catalog with the presets of both catalogs, where the
+// presets of other replace those with the same name.
+func (c Catalog) Merge(other Catalog) Catalog {
+	merged := make(Catalog, len(c)+len(other))
+	for name, preset := range c {
+		merged[name] = preset
+	}
+	for name, preset := range other {
+		merged[name] = preset
+	}
+	return merged
+}
+
+// Names returns the sorted names of the presets.
+func (c Catalog) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the preset with a name, ignoring case.
+func (c Catalog) Lookup(name string) (Preset, error) {
+	preset, found := c[strings.ToLower(name)]
+	if !found {
+		return Preset{}, fmt.Errorf("%w %q, the presets are %s", ErrUnknownPreset, name, strings.Join(c.Names(), ", "))
+	}
+	return preset, nil
+}
@@ -0,0 +1,73 @@
+package preset
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreset(t *testing.T) {
+	t.Run("Built-in presets are valid", func(t *testing.T) {
+		catalog := Builtin()
+		assert.Subset(t, catalog.Names(), []string{"cpu", "memory", "paging-file", "disk-latency", "disk-queue", "nic-errors"})
+
+		for name, preset := range catalog {
+			assert.NotEmpty(t, preset.Description, name)
+			for _, counter := range preset.Counters {
+				_, err := counterpath.Parse(counter)
+				assert.Nil(t, err, name)
+			}
+			for _, threshold := range []string{preset.Warning, preset.Critical} {
+				if threshold != "" {
+					assert.NotNil(t, nagios.ParseRangeString(threshold), name)
+				}
+			}
+		}
+	})
+
+	t.Run("Presets are looked up ignoring case", func(t *testing.T) {
+		preset, err := Builtin().Lookup("CPU")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{`\Processor(_Total)\% Processor Time`}, preset.Counters)
+
+		_, err = Builtin().Lookup("gpu")
+		assert.ErrorIs(t, err, ErrUnknownPreset)
+		assert.Contains(t, err.Error(), "cpu, disk-free")
+	})
+
+	t.Run("User presets are added and replace built-in ones", func(t *testing.T) {
+		user, err := Parse([]byte(`
+cpu:
+  description: Processor time of the first processor
+  counters: ['\Processor(0)\% Processor Time']
+  unit: ""
+sql-batches:
+  description: SQL Server batch requests
+  counters: ['\SQLServer:SQL Statistics\Batch Requests/sec']
+`))
+		assert.Nil(t, err)
+
+		catalog := Builtin().Merge(user)
+		preset, err := catalog.Lookup("cpu")
+		assert.Nil(t, err)
+		assert.Equal(t, `\Processor(0)\% Processor Time`, preset.Counters[0])
+		assert.Equal(t, "", *preset.Unit)
+		assert.Equal(t, "", preset.Warning)
+
+		_, err = catalog.Lookup("sql-batches")
+		assert.Nil(t, err)
+		_, err = catalog.Lookup("disk-queue")
+		assert.Nil(t, err)
+	})
+
+	t.Run("Invalid catalogs are reported", func(t *testing.T) {
+		_, err := Parse([]byte("cpu:\n  counters: ['\\Processor(_Total)\\% Processor Time']\n  treshold: 80\n"))
+		assert.ErrorIs(t, err, ErrInvalidPreset)
+
+		_, err = Parse([]byte("cpu:\n  description: no counters\n"))
+		assert.ErrorIs(t, err, ErrInvalidPreset)
+	})
+}
@@ -0,0 +1,142 @@
+/*
+Package units converts values between units of the same dimension, such as
+bytes and GiB or 100ns ticks and seconds, and scales values to the unit
+which reads best.
+
+Every dimension has a base unit which values are converted to for
+performance data, so that graphs keep working whichever unit is shown to
+people.
+*/
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownUnit indicates that a unit name is not one of the supported
+	// units.
+	ErrUnknownUnit = errors.New("unknown unit")
+
+	// ErrIncompatibleUnits indicates that a conversion was requested between
+	// units of different dimensions, such as bytes and seconds.
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+// Dimension is a kind of quantity, such as an amount of data or time.
+type Dimension struct {
+
+	// Name describes the dimension, i.e. bytes.
+	Name string
+
+	// Base is the name of the unit every value of the dimension is
+	// converted to for performance data.
+	Base string
+
+	// PerfDataUnit is the unit of measurement of the base unit in
+	// performance data, which is empty when Nagios has no unit for it.
+	PerfDataUnit string
+
+	// scale are the units chosen from by Autoscale, smallest first.
+	scale []string
+}
+
+// Unit is a unit of a dimension.
+type Unit struct {
+	Name      string
+	Dimension *Dimension
+
+	// factor is the number of base units in one of this unit.
+	factor float64
+}
+
+var (
+	bytesDimension    = &Dimension{Name: "bytes", Base: "B", PerfDataUnit: "B", scale: []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}}
+	bitsDimension     = &Dimension{Name: "bits", Base: "bit", scale: []string{"bit", "kbit", "Mbit", "Gbit", "Tbit"}}
+	timeDimension     = &Dimension{Name: "time", Base: "s", PerfDataUnit: "s", scale: []string{"ns", "us", "ms", "s", "min", "h", "d"}}
+	byteRateDimension = &Dimension{Name: "bytes per second", Base: "B/s", scale: []string{"B/s", "KiB/s", "MiB/s", "GiB/s", "TiB/s"}}
+	bitRateDimension  = &Dimension{Name: "bits per second", Base: "bit/s", scale: []string{"bit/s", "kbit/s", "Mbit/s", "Gbit/s", "Tbit/s"}}
+)
+
+// catalog lists every supported unit by its lower-cased name.
+var catalog = map[string]Unit{}
+
+func init() {
+	add := func(dimension *Dimension, factors map[string]float64) {
+		for name, factor := range factors {
+			catalog[strings.ToLower(name)] = Unit{Name: name, Dimension: dimension, factor: factor}
+		}
+	}
+
+	byteFactors := map[string]float64{
+		"B": 1, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12, "PB": 1e15,
+		"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40, "PiB": 1 << 50,
+	}
+	bitFactors := map[string]float64{
+		"bit": 1, "kbit": 1e3, "Mbit": 1e6, "Gbit": 1e9, "Tbit": 1e12,
+	}
+
+	add(bytesDimension, byteFactors)
+	add(bitsDimension, bitFactors)
+	add(timeDimension, map[string]float64{
+		"ticks": 1e-7, "ns": 1e-9, "us": 1e-6, "ms": 1e-3, "s": 1, "min": 60, "h": 3600, "d": 86400,
+	})
+
+	perSecond := func(factors map[string]float64) map[string]float64 {
+		rates := make(map[string]float64, len(factors))
+		for name, factor := range factors {
+			rates[name+"/s"] = factor
+		}
+		return rates
+	}
+	add(byteRateDimension, perSecond(byteFactors))
+	add(bitRateDimension, perSecond(bitFactors))
+}
+
+// Lookup returns the unit with a name, ignoring case.
+func Lookup(name string) (Unit, error) {
+	unit, found := catalog[strings.ToLower(strings.TrimSpace(name))]
+	if !found {
+		return Unit{}, fmt.Errorf("%w: %q", ErrUnknownUnit, name)
+	}
+	return unit, nil
+}
+
+// Base returns the base unit of the dimension of a unit.
+func (u Unit) Base() Unit {
+	return catalog[strings.ToLower(u.Dimension.Base)]
+}
+
+// Convert converts a value from one unit to another of the same dimension.
+func Convert(value float64, from Unit, to Unit) (float64, error) {
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("%w: %s is %s while %s is %s", ErrIncompatibleUnits, from.Name, from.Dimension.Name, to.Name, to.Dimension.Name)
+	}
+	return value * from.factor / to.factor, nil
+}
+
+// Autoscale converts a value to the largest unit of its dimension in which
+// it is at least 1, or the smallest unit for values which are smaller.
+func Autoscale(value float64, unit Unit) (float64, Unit) {
+	base := value * unit.factor
+
+	chosen := catalog[strings.ToLower(unit.Dimension.scale[0])]
+	for _, name := range unit.Dimension.scale[1:] {
+		candidate := catalog[strings.ToLower(name)]
+		if math.Abs(base) < candidate.factor {
+			break
+		}
+		chosen = candidate
+	}
+
+	return base / chosen.factor, chosen
+}
+
+// Format returns a value in a unit with at most two decimals, i.e. 1.5 GiB.
+func Format(value float64, unit Unit) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64) + " " + unit.Name
+}
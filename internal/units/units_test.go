@@ -0,0 +1,64 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnits(t *testing.T) {
+	lookup := func(t *testing.T, name string) Unit {
+		unit, err := Lookup(name)
+		assert.Nil(t, err)
+		return unit
+	}
+
+	t.Run("Units are looked up ignoring case", func(t *testing.T) {
+		assert.Equal(t, "GiB", lookup(t, "gib").Name)
+		assert.Equal(t, "Mbit/s", lookup(t, "MBIT/S").Name)
+
+		_, err := Lookup("furlong")
+		assert.ErrorIs(t, err, ErrUnknownUnit)
+	})
+
+	t.Run("Values are converted within a dimension", func(t *testing.T) {
+		value, err := Convert(34359738368, lookup(t, "B"), lookup(t, "GiB"))
+		assert.Nil(t, err)
+		assert.Equal(t, 32.0, value)
+
+		value, err = Convert(25000000, lookup(t, "ticks"), lookup(t, "s"))
+		assert.Nil(t, err)
+		assert.InDelta(t, 2.5, value, 1e-9)
+
+		value, err = Convert(1.5, lookup(t, "MB/s"), lookup(t, "KB/s"))
+		assert.Nil(t, err)
+		assert.Equal(t, 1500.0, value)
+
+		_, err = Convert(1, lookup(t, "B"), lookup(t, "bit"))
+		assert.ErrorIs(t, err, ErrIncompatibleUnits)
+	})
+
+	t.Run("Every unit has a base unit", func(t *testing.T) {
+		assert.Equal(t, "B", lookup(t, "TiB").Base().Name)
+		assert.Equal(t, "s", lookup(t, "ticks").Base().Name)
+		assert.Equal(t, "bit/s", lookup(t, "Gbit/s").Base().Name)
+		assert.Equal(t, "", lookup(t, "Gbit/s").Dimension.PerfDataUnit)
+	})
+
+	t.Run("Values are scaled to the unit which reads best", func(t *testing.T) {
+		value, unit := Autoscale(34359738368, lookup(t, "B"))
+		assert.Equal(t, "32 GiB", Format(value, unit))
+
+		value, unit = Autoscale(1536, lookup(t, "MiB"))
+		assert.Equal(t, "1.5 GiB", Format(value, unit))
+
+		value, unit = Autoscale(0.25, lookup(t, "ms"))
+		assert.Equal(t, "250 us", Format(value, unit))
+
+		value, unit = Autoscale(0, lookup(t, "GiB"))
+		assert.Equal(t, "0 B", Format(value, unit))
+
+		value, unit = Autoscale(125000000, lookup(t, "bit/s"))
+		assert.Equal(t, "125 Mbit/s", Format(value, unit))
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// counterName returns the name of the counter of a check, or the whole path
+// for the result of an expression.
+func (check counterCheck) counterName() string {
+	if check.counter.Counter == "" {
+		return check.path
+	}
+	return check.counter.Counter
+}
+
+// expandLabel replaces the {instance}, {object}, {counter} and {index}
+// placeholders of a label, where index is the position of the instance in
+// the results of the counter, starting at 0.
+func expandLabel(template string, check counterCheck, instance string, index int) string {
+	if !strings.Contains(template, "{") {
+		return template
+	}
+
+	return strings.NewReplacer(
+		"{instance}", instance,
+		"{object}", check.counter.Object,
+		"{counter}", check.counterName(),
+		"{index}", strconv.Itoa(index),
+	).Replace(template)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandLabel(t *testing.T) {
+	disk := counterCheck{
+		path:    `\LogicalDisk(*)\% Free Space`,
+		counter: counterpath.CounterPath{Object: "LogicalDisk", Instance: &counterpath.Instance{Name: "*"}, Counter: "% Free Space"},
+	}
+	expression := counterCheck{path: "100 * a / b"}
+
+	tests := []struct {
+		template string
+		check    counterCheck
+		instance string
+		index    int
+		expected string
+	}{
+		{"free", disk, "C:", 0, "free"},
+		{"{instance} free", disk, "C:", 0, "C: free"},
+		{"{object}_{instance}_{counter}", disk, "D:", 1, "LogicalDisk_D:_% Free Space"},
+		{"disk{index}", disk, "D:", 1, "disk1"},
+		{"{instance}{instance}", disk, "C:", 0, "C:C:"},
+		{"{unknown} {instance", disk, "C:", 0, "{unknown} {instance"},
+		{"{counter} of {instance}", expression, "eth0", 0, "100 * a / b of eth0"},
+		{"{object}{instance}", expression, "", 0, ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, expandLabel(test.template, test.check, test.instance, test.index), test.template)
+	}
+}
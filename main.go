@@ -1,18 +1,13 @@
 package main
 
 import (
-	"bytes"
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"monitoring-agent-client-check-nt-replacement/internal/httpclient"
 	"monitoring-agent-client-check-nt-replacement/internal/nagios"
-	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -31,6 +26,29 @@ func (sf *stringFlag) String() string {
 	return sf.value
 }
 
+// stringListFlag is a flag which may be given more than once.
+type stringListFlag struct {
+	values []string
+}
+
+func (sf *stringListFlag) Set(x string) error {
+	sf.values = append(sf.values, x)
+	return nil
+}
+
+func (sf *stringListFlag) String() string {
+	return strings.Join(sf.values, ", ")
+}
+
+// first returns the first value given, or an empty string if the flag was
+// not given.
+func (sf stringListFlag) first() string {
+	if len(sf.values) == 0 {
+		return ""
+	}
+	return sf.values[0]
+}
+
 type CounterResult struct {
 	Results []CounterResultItem
 }
@@ -38,6 +56,10 @@ type CounterResultItem struct {
 	CounterName  string
 	InstanceName string
 	Value        string
+
+	// statistics are the statistics of the samples which are not the value,
+	// when more than one sample is taken.
+	statistics []sampleStatistic
 }
 
 func die(stdout io.Writer, message string) int {
@@ -58,7 +80,16 @@ func enableTimeout(timeout string) time.Duration {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		os.Exit(runMigrate(os.Stdout, os.Stderr, os.Args[2:]))
+	}
+
 	httpClient := httpclient.NewHTTPClient()
+
+	if len(os.Args) > 1 && os.Args[1] == serveNSClientCommand {
+		os.Exit(runServeNSClient(os.Stderr, os.Args[2:], httpClient))
+	}
+
 	invokeClient(os.Stdout, httpClient)
 }
 
@@ -70,137 +101,327 @@ func invokeClient(stdout io.Writer, httpClient httpclient.Interface) {
 
 	defer plugin.ReturnCheckResults()
 
-	hostname := flag.String("host", "", "hostname or ip")
-	port := flag.Int("port", 9000, "port number")
-	username := flag.String("username", os.Getenv("MONITORING_AGENT_USERNAME"), "username")
-	password := flag.String("password", os.Getenv("MONITORING_AGENT_PASSWORD"), "password")
-	counterName := flag.String("counter", "", "counter path (i.e. \\PhysicalDisk(_Total)\\Avg. Disk Queue Length)")
+	agent := addConnectionFlags(flag.CommandLine, "")
 
-	var warningThreshold stringFlag
-	var criticalThreshold stringFlag
+	var counterNames stringListFlag
+	var warningThreshold stringListFlag
+	var criticalThreshold stringListFlag
+	var counterLabels stringListFlag
+	var counterUnits stringListFlag
 
-	flag.Var(&warningThreshold, "warning", "warning threshold")
-	flag.Var(&criticalThreshold, "critical", "critical threshold")
+	flag.Var(&counterNames, "counter", "counter path (i.e. \\PhysicalDisk(_Total)\\Avg. Disk Queue Length), may be repeated")
+	flag.Var(&warningThreshold, "warning", "warning threshold, may be repeated for each counter")
+	flag.Var(&criticalThreshold, "critical", "critical threshold, may be repeated for each counter")
+	flag.Var(&counterLabels, "label", "output label with optional {instance}, {object}, {counter} and {index} placeholders, may be repeated for each counter")
+	flag.Var(&counterUnits, "unit", "unit of measurement, may be repeated for each counter (default \"%\")")
+	var includeInstances stringListFlag
+	var excludeInstances stringListFlag
 
-	if warningThreshold.set {
-		plugin.WarningThreshold = warningThreshold.value
-	}
-	if criticalThreshold.set {
-		plugin.CriticalThreshold = criticalThreshold.value
-	}
+	flag.Var(&includeInstances, "include-instance", "only check instances matching this regular expression, may be repeated")
+	flag.Var(&excludeInstances, "exclude-instance", "do not check instances matching this regular expression, may be repeated")
+	emptyStateName := flag.String("empty-state", nagios.StateUNKNOWNLabel, "state when a counter returns no values or no instances are left after filtering")
+	nanStateName := flag.String("nan-state", nagios.StateUNKNOWNLabel, "state when a counter returns a value which is not numeric")
+	undeterminedStateName := flag.String("u-state", nagios.StateUNKNOWNLabel, "state when a counter returns the undetermined value U")
 
-	counterlabel := flag.String("label", "", "output label")
-	counterUnit := flag.String("unit", "%", "unit of measurement")
+	thresholdFile := flag.String("threshold-file", "", "file of per instance thresholds, a line per instance in the form instance=warning,critical")
+	aggregate := flag.String("aggregate", "", "reduce the instances of each counter to one value with sum, avg, min, max, count, median or p95")
+	aggregatePerfData := flag.Bool("aggregate-perfdata", false, "also emit the performance data of each aggregated instance")
 
-	cacertificateFilePath := flag.String("cacert", os.Getenv("MONITORING_AGENT_CA_CERTIFICATE_PATH"), "CA certificate")
-	certificateFilePath := flag.String("certificate", os.Getenv("MONITORING_AGENT_CLIENT_CERTIFICATE_PATH"), "certificate file")
-	privateKeyFilePath := flag.String("key", os.Getenv("MONITORING_AGENT_CLIENT_KEY_PATH"), "key file")
-	timeoutString := flag.String("timeout", "10s", "timeout (e.g. 10s)")
-	makeInsecure := flag.Bool("insecure", false, "ignore TLS Certificate checks")
+	perfDataCollision := flag.String("perfdata-collision", "suffix", "when performance data labels collide, error, suffix or keep-last")
 
-	flag.Parse()
+	rate := flag.Bool("rate", false, "evaluate the per second rate of cumulative counters since the previous check")
+	counterMax := flag.Float64("counter-max", 0, "value at which the counters wrap around to 0 (i.e. 4294967295), used with -rate")
+	rateFirstStateName := flag.String("rate-first-state", nagios.StateOKLabel, "state while there is no previous value to calculate a rate from")
 
-	if *hostname == "" {
-		die(stdout, "hostname is not set")
-		return
-	}
-	if *password == "" {
-		die(stdout, "password is not set")
-		return
-	}
+	expression := flag.String("expr", "", "check an expression over the counters named with -counter name=path (i.e. \"100 * a / b\")")
 
-	timeout := enableTimeout(*timeoutString)
+	samples := flag.Int("samples", 1, "number of samples to take of each counter, within the timeout")
+	sampleInterval := flag.Duration("interval", time.Second, "time between samples")
+	statistic := flag.String("statistic", defaultStatistic, "statistic of the samples the thresholds apply to, min, avg, max or stddev")
 
-	restRequest := map[string]interface{}{
-		"CounterPath": counterName,
-	}
+	top := flag.Int("top", 0, "list the instances with the N highest values in the long output")
+	topPerfData := flag.Bool("top-perfdata", false, "only emit the performance data of the -top instances")
 
-	url := fmt.Sprintf("https://%s:%d/v1/os_specific", *hostname, *port)
+	unitFrom := flag.String("unit-from", "", "unit of the counter values, i.e. B, ticks or bit/s, which are converted to the base unit in the performance data")
+	unitTo := flag.String("unit-to", "", "unit of the output and thresholds, i.e. GiB (default -unit-from)")
+	autoscale := flag.Bool("autoscale", false, "show each value in the unit which reads best, requires -unit-from")
 
-	httpClient.SetTimeout(timeout)
+	descriptionFormat := flag.String("format", "", "printf style output for each value (i.e. \"Queue length is %.2f\")")
 
-	transport := new(http.Transport)
-	transport.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: *makeInsecure,
-	}
+	presetName := flag.String("preset", "", "check bundling counters, label, unit, thresholds and filters, i.e. cpu, memory, disk-latency or nic-errors, each overridden by its flag")
+	presetFile := flag.String("preset-file", os.Getenv("MONITORING_AGENT_PRESET_FILE"), "YAML file of presets adding to or replacing the built-in ones")
 
-	if *certificateFilePath != "" && *privateKeyFilePath != "" {
-		certificateToLoad, err := tls.LoadX509KeyPair(*certificateFilePath, *privateKeyFilePath)
-		if err != nil {
-			die(stdout, fmt.Sprintf("error loading certificate pair %s", err.Error()))
+	stateDirectory := flag.String("state-dir", defaultStateDirectory(), "directory used to keep state between checks")
+
+	// check_nt compatible flags, these allow existing command definitions to
+	// be migrated by only replacing the path to the plugin.
+	addCheckNtConnectionFlags(flag.CommandLine, agent)
+	checkNtVariable := flag.String("v", checkNtVariableCounter, "variable to check, COUNTER, CPULOAD, MEMUSE, USEDDISKSPACE, UPTIME, PROCSTATE, INSTANCES or NICUTIL (check_nt compatible)")
+	checkNtParams := flag.String("l", "", "parameters for the variable (check_nt compatible)")
+	checkNtTimeout := flag.Int("t", 0, "timeout in seconds (check_nt compatible)")
+	checkNtDisplay := flag.String("d", "", "SHOWALL to list all values (check_nt compatible)")
+	uptimeUnit := flag.String("u", "", "unit of the UPTIME thresholds, seconds, minutes, hours or days (default minutes)")
+
+	var checkNtWarningThreshold stringFlag
+	var checkNtCriticalThreshold stringFlag
+
+	flag.Var(&checkNtWarningThreshold, "w", "warning threshold (check_nt compatible)")
+	flag.Var(&checkNtCriticalThreshold, "c", "critical threshold (check_nt compatible)")
+
+	flag.Parse()
+
+	variable := strings.ToUpper(*checkNtVariable)
+
+	if *presetName != "" {
+		if variable != checkNtVariableCounter {
+			die(stdout, fmt.Sprintf("preset %s can only be used with the %s variable", *presetName, checkNtVariableCounter))
 			return
 		}
-		transport.TLSClientConfig.Certificates = []tls.Certificate{certificateToLoad}
-	}
-
-	if *cacertificateFilePath != "" {
-		caCertificate, err := ioutil.ReadFile(*cacertificateFilePath)
+		selected, err := loadPreset(*presetName, *presetFile)
+		if err == nil {
+			err = applyPreset(flag.CommandLine, selected)
+		}
 		if err != nil {
-			die(stdout, fmt.Sprintf("error loading ca certificate %s", err.Error()))
+			die(stdout, err.Error())
 			return
 		}
-		CACertificatePool := x509.NewCertPool()
-		CACertificatePool.AppendCertsFromPEM(caCertificate)
-		transport.TLSClientConfig.RootCAs = CACertificatePool
 	}
 
-	httpClient.SetTransport(transport)
+	if checkNtWarningThreshold.set || checkNtCriticalThreshold.set {
+		translatedWarning, translatedCritical := translateCheckNtThresholds(variable, checkNtWarningThreshold.value, checkNtCriticalThreshold.value)
+		if checkNtWarningThreshold.set {
+			warningThreshold.Set(translatedWarning)
+		}
+		if checkNtCriticalThreshold.set {
+			criticalThreshold.Set(translatedCritical)
+		}
+	}
 
-	byteArray, _ := json.Marshal(restRequest)
-	byteArrayBuffer := bytes.NewBuffer(byteArray)
+	plugin.WarningThreshold = warningThreshold.String()
+	plugin.CriticalThreshold = criticalThreshold.String()
 
-	req, err := http.NewRequest(http.MethodPost, url, byteArrayBuffer)
-	if err != nil {
-		panic(fmt.Errorf("got http request error %s", err.Error()))
+	if *checkNtTimeout > 0 {
+		*agent.timeoutString = fmt.Sprintf("%ds", *checkNtTimeout)
 	}
-	req.SetBasicAuth(*username, *password)
 
-	response, err := httpClient.Do(req)
-
-	if err != nil {
-		die(stdout, fmt.Sprintf("got httpClient error %s", err.Error()))
+	if *checkNtDisplay != "" && !strings.EqualFold(*checkNtDisplay, checkNtDisplayShowAll) {
+		die(stdout, fmt.Sprintf("unsupported display option %s", *checkNtDisplay))
 		return
 	}
 
-	defer response.Body.Close()
-
-	if response.StatusCode != 200 {
-		errorBodyContent, _ := ioutil.ReadAll(response.Body)
-		die(stdout, fmt.Sprintf("Response code: %d\n%s", response.StatusCode, errorBodyContent))
+	collisionPolicy, err := nagios.ParsePerfDataCollisionPolicy(*perfDataCollision)
+	if err != nil {
+		die(stdout, err.Error())
 		return
 	}
+	plugin.SetPerfDataCollisionPolicy(collisionPolicy)
+
+	var counterChecks []counterCheck
+	var counterSettings counterOptions
+
+	switch variable {
+	case checkNtVariableCounter:
+		if *checkNtParams != "" {
+			counterParams, err := parseCheckNtCounterParams(*checkNtParams)
+			if err != nil {
+				die(stdout, err.Error())
+				return
+			}
+			counterNames.Set(counterParams.path)
+			if *descriptionFormat == "" {
+				*descriptionFormat = counterParams.description
+			}
+			if counterParams.unit != "" && len(counterUnits.values) == 0 {
+				counterUnits.Set(counterParams.unit)
+			}
+			counterSettings.perfDataMin = counterParams.min
+			counterSettings.perfDataMax = counterParams.max
+		}
 
-	var decodedResponse CounterResult
-
-	decoder := json.NewDecoder(response.Body)
-	decoder.DisallowUnknownFields()
-	decoder.Decode(&decodedResponse)
+		defaultUnit := defaultCounterUnit
+		if *rate {
+			defaultUnit = defaultRateUnit
+		}
 
-	plugin.ExitStatusCode = nagios.StateOKExitCode
+		if *expression != "" {
+			// The counters are only the inputs of the expression, the labels,
+			// units and thresholds apply to its result.
+			inputs, err := newCounterChecks(counterNames, stringListFlag{}, stringListFlag{}, defaultUnit, stringListFlag{}, stringListFlag{})
+			if err == nil {
+				err = parseCounterPaths(inputs)
+			}
+			if err != nil {
+				die(stdout, err.Error())
+				return
+			}
+			counterSettings.expression, err = newCounterExpression(*expression, inputs)
+			if err != nil {
+				die(stdout, err.Error())
+				return
+			}
+			counterNames = stringListFlag{values: []string{*expression}}
+			defaultUnit = defaultExpressionUnit
+		}
 
-	for _, outputValue := range decodedResponse.Results {
+		counterChecks, err = newCounterChecks(counterNames, counterLabels, counterUnits, defaultUnit, warningThreshold, criticalThreshold)
+		if err == nil && counterSettings.expression == nil {
+			err = parseCounterPaths(counterChecks)
+		}
+		if err != nil {
+			die(stdout, err.Error())
+			return
+		}
 
-		thisCounterLabel := outputValue.InstanceName
+		if *thresholdFile != "" {
+			fileWarnings, fileCriticals, err := loadThresholdFile(*thresholdFile)
+			if err != nil {
+				die(stdout, err.Error())
+				return
+			}
+			for i := range counterChecks {
+				counterChecks[i].warning = append(append(thresholdRules{}, fileWarnings...), counterChecks[i].warning...)
+				counterChecks[i].critical = append(append(thresholdRules{}, fileCriticals...), counterChecks[i].critical...)
+			}
+		}
 
-		if *counterlabel != "" {
-			thisCounterLabel = *counterlabel
+		counterSettings.conversion, err = newUnitConversion(*unitFrom, *unitTo, *autoscale)
+		if err != nil {
+			die(stdout, err.Error())
+			return
+		}
+		for i := range counterChecks {
+			counterChecks[i].warning, err = counterSettings.conversion.scaleRules(counterChecks[i].warning)
+			if err == nil {
+				counterChecks[i].critical, err = counterSettings.conversion.scaleRules(counterChecks[i].critical)
+			}
+			if err != nil {
+				die(stdout, err.Error())
+				return
+			}
 		}
 
-		perfdata := nagios.PerformanceData{
-			Label:             thisCounterLabel,
-			Value:             outputValue.Value,
-			UnitOfMeasurement: *counterUnit,
+		counterSettings.description = *descriptionFormat
+		counterSettings.filter, err = newInstanceFilter(includeInstances.values, excludeInstances.values)
+		if err != nil {
+			die(stdout, err.Error())
+			return
+		}
+		counterSettings.emptyState, err = nagios.ParseServiceState(*emptyStateName)
+		if err != nil {
+			die(stdout, err.Error())
+			return
 		}
-		if warningThreshold.set {
-			perfdata.Warn = warningThreshold.value
+		counterSettings.nanState, err = nagios.ParseServiceState(*nanStateName)
+		if err != nil {
+			die(stdout, err.Error())
+			return
 		}
-		if criticalThreshold.set {
-			perfdata.Crit = criticalThreshold.value
+		counterSettings.undeterminedState, err = nagios.ParseServiceState(*undeterminedStateName)
+		if err != nil {
+			die(stdout, err.Error())
+			return
 		}
-		plugin.AddPerfData(false, perfdata)
-		plugin.EvaluateThreshold(perfdata)
+		counterSettings.aggregate = strings.ToLower(*aggregate)
+		if err = validateAggregate(counterSettings.aggregate); err != nil {
+			die(stdout, err.Error())
+			return
+		}
+		counterSettings.aggregatePerfData = *aggregatePerfData
+		counterSettings.samples = *samples
+		counterSettings.interval = *sampleInterval
+		counterSettings.statistic = strings.ToLower(*statistic)
+		if err = validateStatistic(counterSettings.statistic); err != nil {
+			die(stdout, err.Error())
+			return
+		}
+		if *samples < 1 {
+			die(stdout, fmt.Sprintf("samples must be at least 1 but got %d", *samples))
+			return
+		}
+		if *samples > 1 && *rate {
+			die(stdout, "samples cannot be combined with rate")
+			return
+		}
+		if *top < 0 {
+			die(stdout, fmt.Sprintf("top must not be negative but got %d", *top))
+			return
+		}
+		counterSettings.top = *top
+		counterSettings.topPerfData = *topPerfData
+		counterSettings.rate = *rate
+		counterSettings.stateDirectory = *stateDirectory
+		counterSettings.counterMax = *counterMax
+		counterSettings.firstRunState, err = nagios.ParseServiceState(*rateFirstStateName)
+		if err != nil {
+			die(stdout, err.Error())
+			return
+		}
+	case checkNtVariableCPULoad, checkNtVariableMemUse, checkNtVariableUsedDiskSpace, checkNtVariableProcState, checkNtVariableInstances:
+	case variableNICUtil:
+		counterSettings.filter, err = newInstanceFilter(includeInstances.values, excludeInstances.values)
+		if err != nil {
+			die(stdout, err.Error())
+			return
+		}
+	case checkNtVariableUptime:
+		if *uptimeUnit == "" {
+			*uptimeUnit = *checkNtParams
+		}
+	default:
+		die(stdout, fmt.Sprintf("unsupported variable %s", *checkNtVariable))
+		return
+	}
+
+	if err := agent.validate(); err != nil {
+		die(stdout, err.Error())
+		return
+	}
+
+	timeout := enableTimeout(*agent.timeoutString)
+
+	if sampling := time.Duration(counterSettings.samples-1) * counterSettings.interval; counterSettings.samples > 1 && sampling >= timeout {
+		die(stdout, fmt.Sprintf("%d samples %s apart do not fit in the %s timeout", counterSettings.samples, counterSettings.interval, timeout))
+		return
+	}
+	counterSettings.sampleDeadline = sampleDeadline(timeout)
+
+	connection, err := agent.connect(httpClient, timeout)
+	if err != nil {
+		die(stdout, err.Error())
+		return
 	}
 
+	plugin.ExitStatusCode = nagios.StateOKExitCode
+
+	switch variable {
+	case checkNtVariableCounter:
+		err = checkCounters(&plugin, connection, counterChecks, counterSettings)
+	case checkNtVariableCPULoad:
+		err = checkCPULoad(&plugin, connection, *stateDirectory, *checkNtParams)
+	case checkNtVariableMemUse:
+		err = checkMemUse(&plugin, connection, warningThreshold.first(), criticalThreshold.first())
+	case checkNtVariableUsedDiskSpace:
+		err = checkUsedDiskSpace(&plugin, connection, *checkNtParams, warningThreshold.first(), criticalThreshold.first())
+	case checkNtVariableUptime:
+		err = checkUptime(&plugin, connection, *uptimeUnit, warningThreshold.first(), criticalThreshold.first())
+	case checkNtVariableProcState:
+		err = checkProcState(&plugin, connection, *checkNtParams, strings.EqualFold(*checkNtDisplay, checkNtDisplayShowAll))
+	case checkNtVariableInstances:
+		err = checkInstances(&plugin, connection, *checkNtParams)
+	case variableNICUtil:
+		err = checkNICUtil(&plugin, connection, counterSettings.filter, warningThreshold.first(), criticalThreshold.first())
+	}
+
+	if err != nil {
+		plugin.ExitStatusCode = nagios.StateUNKNOWNExitCode
+		die(stdout, err.Error())
+		return
+	}
+}
+
+// setServiceOutput sets the one-line summary to the label of the current
+// state, followed by the summary text if there is any.
+func setServiceOutput(plugin *nagios.Plugin, summary string) {
 	plugin.ServiceOutput = nagios.StateOKLabel
 
 	if plugin.ExitStatusCode == nagios.StateWARNINGExitCode {
@@ -214,4 +435,8 @@ func invokeClient(stdout io.Writer, httpClient httpclient.Interface) {
 	if plugin.ExitStatusCode == nagios.StateUNKNOWNExitCode {
 		plugin.ServiceOutput = nagios.StateUNKNOWNLabel
 	}
+
+	if summary != "" {
+		plugin.ServiceOutput = fmt.Sprintf("%s: %s", plugin.ServiceOutput, summary)
+	}
 }
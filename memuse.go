@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+)
+
+const (
+	memUseCommittedCounterPath = `\Memory\Committed Bytes`
+	memUseLimitCounterPath     = `\Memory\Commit Limit`
+)
+
+const bytesPerMegabyte = 1024 * 1024
+
+// fetchMemoryUse returns the committed bytes and the commit limit, which are
+// what check_nt reports as used and total memory.
+func fetchMemoryUse(connection agentConnection) (float64, float64, error) {
+	committed, err := connection.queryCounterValue(memUseCommittedCounterPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limit, err := connection.queryCounterValue(memUseLimitCounterPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit <= 0 {
+		return 0, 0, fmt.Errorf("commit limit %.0f returned for %s is not positive", limit, memUseLimitCounterPath)
+	}
+
+	return committed, limit, nil
+}
+
+// checkMemUse emulates the check_nt MEMUSE variable. Thresholds are given as
+// a percentage of the commit limit and are converted to bytes for the
+// performance data.
+func checkMemUse(plugin *nagios.Plugin, connection agentConnection, warningThreshold string, criticalThreshold string) error {
+	committed, limit, err := fetchMemoryUse(connection)
+	if err != nil {
+		return err
+	}
+
+	warning, err := scaleThreshold(warningThreshold, limit/100)
+	if err != nil {
+		return err
+	}
+	critical, err := scaleThreshold(criticalThreshold, limit/100)
+	if err != nil {
+		return err
+	}
+
+	perfdata := nagios.PerformanceData{
+		Label:             "Memory usage",
+		Value:             strconv.FormatFloat(committed, 'f', 0, 64),
+		UnitOfMeasurement: "B",
+		Warn:              warning,
+		Crit:              critical,
+		Min:               "0",
+		Max:               strconv.FormatFloat(limit, 'f', 0, 64),
+	}
+	plugin.AddPerfData(false, perfdata)
+	plugin.EvaluateThreshold(perfdata)
+
+	usedPercent := committed / limit * 100
+	setServiceOutput(plugin, fmt.Sprintf(
+		"Memory usage: total:%.2f MB - used: %.2f MB (%.0f%%) - free: %.2f MB (%.0f%%)",
+		limit/bytesPerMegabyte,
+		committed/bytesPerMegabyte,
+		usedPercent,
+		(limit-committed)/bytesPerMegabyte,
+		100-usedPercent,
+	))
+	return nil
+}
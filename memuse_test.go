@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemUse(t *testing.T) {
+	memory := func(committed string, limit string) agentConnection {
+		return newMockConnection(map[string][]CounterResultItem{
+			memUseCommittedCounterPath: {{CounterName: "Committed Bytes", Value: committed}},
+			memUseLimitCounterPath:     {{CounterName: "Commit Limit", Value: limit}},
+		})
+	}
+
+	t.Run("Used, total and free memory are reported with the percentages", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkMemUse(plugin, memory("6442450944", "8589934592"), "", "")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateOKExitCode, exitCode)
+		assert.Contains(t, output, "OK: Memory usage: total:8192.00 MB - used: 6144.00 MB (75%) - free: 2048.00 MB (25%)")
+	})
+
+	t.Run("The performance data is in bytes up to the commit limit", func(t *testing.T) {
+		output, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkMemUse(plugin, memory("6442450944", "8589934592"), "@80:", "@90:")
+		})
+		assert.Nil(t, err)
+		assert.Contains(t, output, "'Memory usage'=6442450944B;@6871947673.6:;@7730941132.8:;0;8589934592")
+	})
+
+	t.Run("Thresholds are percentages of the commit limit", func(t *testing.T) {
+		warning, critical := translateCheckNtThresholds(checkNtVariableMemUse, "80", "90")
+
+		tests := []struct {
+			committed        string
+			expectedExitCode int
+		}{
+			{"500", nagios.StateOKExitCode},
+			{"799", nagios.StateOKExitCode},
+			{"800", nagios.StateWARNINGExitCode},
+			{"899", nagios.StateWARNINGExitCode},
+			{"900", nagios.StateCRITICALExitCode},
+			{"1000", nagios.StateCRITICALExitCode},
+		}
+
+		for _, test := range tests {
+			_, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+				return checkMemUse(plugin, memory(test.committed, "1000"), warning, critical)
+			})
+			assert.Nil(t, err, test.committed)
+			assert.Equal(t, test.expectedExitCode, exitCode, test.committed)
+		}
+	})
+
+	t.Run("A commit limit which is not positive and invalid thresholds are errors", func(t *testing.T) {
+		_, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkMemUse(plugin, memory("100", "0"), "", "")
+		})
+		assert.Contains(t, err.Error(), "is not positive")
+
+		_, _, err = runCheck(func(plugin *nagios.Plugin) error {
+			return checkMemUse(plugin, memory("100", "1000"), "80:70", "")
+		})
+		assert.EqualError(t, err, `invalid threshold "80:70"`)
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monitoring-agent-client-check-nt-replacement/internal/migrate"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const migrateCommand = "migrate"
+
+const defaultPluginName = "monitoring-agent-check-nt-replacement"
+
+// runMigrate implements the migrate subcommand, which rewrites the check_nt
+// invocations within Nagios object configuration files. Either a unified
+// diff is printed or the rewritten files are written to an output directory,
+// followed by a report of anything which could not be translated, in which
+// case the exit code is 1 so that scripted migrations notice.
+func runMigrate(stdout io.Writer, stderr io.Writer, args []string) int {
+	flags := flag.NewFlagSet(migrateCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s %s [-diff | -output-dir DIRECTORY] [-plugin-name NAME] [-port PORT] FILE...\n", filepath.Base(os.Args[0]), migrateCommand)
+		flags.PrintDefaults()
+	}
+
+	pluginName := flags.String("plugin-name", defaultPluginName, "file name of the plugin replacing check_nt")
+	port := flags.String("port", "", "monitoring-agent port to use in place of the check_nt port")
+	showDiff := flags.Bool("diff", false, "print a unified diff instead of writing the rewritten files")
+	outputDirectory := flags.String("output-dir", "", "directory the rewritten files are written to")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 || (*showDiff == (*outputDirectory != "")) {
+		flags.Usage()
+		return 2
+	}
+
+	var files []migrate.File
+	for _, path := range flags.Args() {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "error reading %s\n", err.Error())
+			return 1
+		}
+		files = append(files, migrate.File{Path: path, Content: string(content)})
+	}
+
+	result := migrate.Migrate(files, migrate.Options{
+		PluginName:         *pluginName,
+		Port:               *port,
+		SupportedVariables: checkNtVariables,
+	})
+
+	report := stdout
+	if *showDiff {
+		// Keep the diff on stdout clean so that it can be applied.
+		report = stderr
+	}
+
+	for i, file := range result.Files {
+		if *showDiff {
+			diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(files[i].Content),
+				B:        difflib.SplitLines(file.Content),
+				FromFile: filepath.ToSlash(filepath.Join("a", file.Path)),
+				ToFile:   filepath.ToSlash(filepath.Join("b", file.Path)),
+				Context:  3,
+			})
+			fmt.Fprint(stdout, diff)
+			continue
+		}
+
+		outputPath := filepath.Join(*outputDirectory, filepath.Clean(file.Path[len(filepath.VolumeName(file.Path)):]))
+		err := os.MkdirAll(filepath.Dir(outputPath), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(outputPath, []byte(file.Content), 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error writing %s\n", err.Error())
+			return 1
+		}
+	}
+
+	if len(result.Issues) == 0 {
+		fmt.Fprintln(report, "All check_nt invocations were translated.")
+		return 0
+	}
+
+	fmt.Fprintf(report, "%d check_nt invocation(s) could not be translated:\n", len(result.Issues))
+	for _, issue := range result.Issues {
+		fmt.Fprintf(report, "%s\n", issue)
+	}
+	return 1
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunMigrate(t *testing.T) {
+	migrateFile := func(t *testing.T, content string) (string, int) {
+		path := filepath.Join(t.TempDir(), "commands.cfg")
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+		var stdout, stderr bytes.Buffer
+		exitCode := runMigrate(&stdout, &stderr, []string{"-diff", path})
+		return stdout.String() + stderr.String(), exitCode
+	}
+
+	t.Run("A complete migration exits with 0", func(t *testing.T) {
+		output, exitCode := migrateFile(t, "define command {\n command_name check_nt_memuse\n command_line check_nt -H $HOSTADDRESS$ -p 12489 -v MEMUSE\n}\n")
+		assert.Equal(t, 0, exitCode)
+		assert.Contains(t, output, "+ command_line monitoring-agent-check-nt-replacement -host $HOSTADDRESS$ -v MEMUSE")
+		assert.Contains(t, output, "All check_nt invocations were translated.")
+	})
+
+	t.Run("Invocations which could not be translated exit with 1", func(t *testing.T) {
+		output, exitCode := migrateFile(t, "define command {\n command_name check_nt_version\n command_line check_nt -H $HOSTADDRESS$ -v CLIENTVERSION\n}\n")
+		assert.Equal(t, 1, exitCode)
+		assert.Contains(t, output, "variable CLIENTVERSION is not supported")
+	})
+
+	t.Run("Usage errors exit with 2", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, runMigrate(&stdout, &stderr, []string{"-diff"}))
+	})
+}
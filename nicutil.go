@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"monitoring-agent-client-check-nt-replacement/internal/units"
+	"strconv"
+	"strings"
+)
+
+// variableNICUtil checks the utilization of each network interface. It is
+// not a check_nt variable, so it is not listed in checkNtVariables.
+const variableNICUtil = "NICUTIL"
+
+const (
+	nicUtilTrafficCounterPath   = `\Network Interface(*)\Bytes Total/sec`
+	nicUtilBandwidthCounterPath = `\Network Interface(*)\Current Bandwidth`
+)
+
+const bitsPerByte = 8
+
+// nicUtilization is the traffic of a network interface along with its
+// bandwidth, both in bits per second.
+type nicUtilization struct {
+	name      string
+	traffic   float64
+	bandwidth float64
+}
+
+func (nic nicUtilization) percent() float64 {
+	return nic.traffic / nic.bandwidth * 100
+}
+
+func (nic nicUtilization) String() string {
+	bitRate, _ := units.Lookup("bit/s")
+	return fmt.Sprintf("%s: %.2f%% (%s of %s)",
+		nic.name,
+		nic.percent(),
+		units.Format(units.Autoscale(nic.traffic, bitRate)),
+		units.Format(units.Autoscale(nic.bandwidth, bitRate)),
+	)
+}
+
+// parseNICValue parses the value a counter returned for an interface.
+func parseNICValue(item CounterResultItem, counterPath string) (float64, error) {
+	value, err := strconv.ParseFloat(item.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q returned for %s of %s is not numeric", item.Value, counterPath, item.InstanceName)
+	}
+	return value, nil
+}
+
+// fetchNICUtilization returns the utilization of the network interfaces kept
+// by filter, joining the traffic and bandwidth counters on the exact instance
+// name, ignoring case.
+// Interfaces which report no bandwidth, such as disconnected or some virtual
+// adapters, cannot have a utilization and are returned as skipped instead.
+func fetchNICUtilization(connection agentConnection, filter instanceFilter) ([]nicUtilization, []string, error) {
+	traffic, err := connection.queryCounter(nicUtilTrafficCounterPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	bandwidth, err := connection.queryCounter(nicUtilBandwidthCounterPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bandwidthByName := map[string]CounterResultItem{}
+	for _, item := range bandwidth.Results {
+		name := strings.ToLower(item.InstanceName)
+		if _, found := bandwidthByName[name]; !found {
+			bandwidthByName[name] = item
+		}
+	}
+
+	var nics []nicUtilization
+	var skipped []string
+	for _, item := range filter.apply(traffic.Results) {
+		bytesPerSecond, err := parseNICValue(item, nicUtilTrafficCounterPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		bandwidthItem, found := bandwidthByName[strings.ToLower(item.InstanceName)]
+		if !found {
+			skipped = append(skipped, item.InstanceName)
+			continue
+		}
+		bitsPerSecond, err := parseNICValue(bandwidthItem, nicUtilBandwidthCounterPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if bitsPerSecond <= 0 {
+			skipped = append(skipped, item.InstanceName)
+			continue
+		}
+
+		nics = append(nics, nicUtilization{
+			name:      item.InstanceName,
+			traffic:   bytesPerSecond * bitsPerByte,
+			bandwidth: bitsPerSecond,
+		})
+	}
+
+	return nics, skipped, nil
+}
+
+// checkNICUtil checks the utilization of each network interface against
+// thresholds given as a percentage of its bandwidth. The traffic performance
+// data has the thresholds converted to bits per second.
+func checkNICUtil(plugin *nagios.Plugin, connection agentConnection, filter instanceFilter, warningThreshold string, criticalThreshold string) error {
+	nics, skipped, err := fetchNICUtilization(connection, filter)
+	if err != nil {
+		return err
+	}
+	if len(nics) == 0 {
+		return fmt.Errorf("no network interfaces with a bandwidth were returned for %s", nicUtilBandwidthCounterPath)
+	}
+
+	var details []string
+	for _, nic := range nics {
+		warning, err := scaleThreshold(warningThreshold, nic.bandwidth/100)
+		if err != nil {
+			return err
+		}
+		critical, err := scaleThreshold(criticalThreshold, nic.bandwidth/100)
+		if err != nil {
+			return err
+		}
+
+		utilization := nagios.PerformanceData{
+			Label:             nic.name + " utilization",
+			Value:             strconv.FormatFloat(nic.percent(), 'f', 2, 64),
+			UnitOfMeasurement: "%",
+			Warn:              warningThreshold,
+			Crit:              criticalThreshold,
+			Min:               "0",
+			Max:               "100",
+		}
+		plugin.AddPerfData(false, utilization)
+		plugin.EvaluateThreshold(utilization)
+
+		plugin.AddPerfData(false, nagios.PerformanceData{
+			Label: nic.name + " traffic",
+			Value: strconv.FormatFloat(nic.traffic, 'f', 0, 64),
+			Warn:  warning,
+			Crit:  critical,
+			Min:   "0",
+			Max:   strconv.FormatFloat(nic.bandwidth, 'f', 0, 64),
+		})
+
+		details = append(details, nic.String())
+	}
+
+	for _, name := range skipped {
+		details = append(details, fmt.Sprintf("%s: skipped, no bandwidth reported", name))
+	}
+
+	if len(details) > 1 {
+		plugin.LongServiceOutput = strings.Join(details, nagios.CheckOutputEOL)
+	}
+
+	if len(nics) == 1 {
+		setServiceOutput(plugin, nics[0].String())
+		return nil
+	}
+
+	busiest := nics[0]
+	for _, nic := range nics[1:] {
+		if nic.percent() > busiest.percent() {
+			busiest = nic
+		}
+	}
+	setServiceOutput(plugin, fmt.Sprintf("%d network interfaces, highest utilization %s", len(nics), busiest))
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNICUtil(t *testing.T) {
+	adapters := func(traffic []CounterResultItem, bandwidth []CounterResultItem) agentConnection {
+		return newMockConnection(map[string][]CounterResultItem{
+			nicUtilTrafficCounterPath:   traffic,
+			nicUtilBandwidthCounterPath: bandwidth,
+		})
+	}
+
+	t.Run("Traffic and bandwidth are joined on the instance name ignoring case", func(t *testing.T) {
+		connection := adapters(
+			[]CounterResultItem{
+				{InstanceName: "Ethernet", Value: "1250000"},
+				{InstanceName: "Wi-Fi", Value: "125000"},
+			},
+			[]CounterResultItem{
+				{InstanceName: "wi-fi", Value: "10000000"},
+				{InstanceName: "ETHERNET", Value: "100000000"},
+			},
+		)
+		nics, skipped, err := fetchNICUtilization(connection, instanceFilter{})
+		assert.Nil(t, err)
+		assert.Empty(t, skipped)
+		assert.Equal(t, []nicUtilization{
+			{name: "Ethernet", traffic: 10000000, bandwidth: 100000000},
+			{name: "Wi-Fi", traffic: 1000000, bandwidth: 10000000},
+		}, nics)
+	})
+
+	t.Run("A single bandwidth is not used for other interfaces", func(t *testing.T) {
+		connection := adapters(
+			[]CounterResultItem{
+				{InstanceName: "Ethernet", Value: "1250000"},
+				{InstanceName: "Wi-Fi", Value: "125000"},
+			},
+			[]CounterResultItem{{InstanceName: "Ethernet", Value: "100000000"}},
+		)
+		nics, skipped, err := fetchNICUtilization(connection, instanceFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Wi-Fi"}, skipped)
+		assert.Len(t, nics, 1)
+	})
+
+	t.Run("Interfaces without a bandwidth are skipped", func(t *testing.T) {
+		connection := adapters(
+			[]CounterResultItem{
+				{InstanceName: "Ethernet", Value: "1250000"},
+				{InstanceName: "Disconnected", Value: "0"},
+			},
+			[]CounterResultItem{
+				{InstanceName: "Ethernet", Value: "100000000"},
+				{InstanceName: "Disconnected", Value: "0"},
+			},
+		)
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkNICUtil(plugin, connection, instanceFilter{}, "80", "90")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateOKExitCode, exitCode)
+		assert.Contains(t, output, "Ethernet: 10.00%")
+		assert.Contains(t, output, "Disconnected: skipped, no bandwidth reported")
+		assert.NotContains(t, output, "'Disconnected utilization'")
+	})
+
+	t.Run("Thresholds are scaled to the bandwidth for the traffic", func(t *testing.T) {
+		connection := adapters(
+			[]CounterResultItem{{InstanceName: "Ethernet", Value: "11250000"}},
+			[]CounterResultItem{{InstanceName: "Ethernet", Value: "100000000"}},
+		)
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkNICUtil(plugin, connection, instanceFilter{}, "80", "90")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateWARNINGExitCode, exitCode)
+		assert.Contains(t, output, "'Ethernet utilization'=90.00%;80;90;0;100")
+		assert.Contains(t, output, "'Ethernet traffic'=90000000;80000000;90000000;0;100000000")
+	})
+
+	t.Run("Having no interface with a bandwidth is an error", func(t *testing.T) {
+		connection := adapters(
+			[]CounterResultItem{{InstanceName: "Ethernet", Value: "1250000"}},
+			[]CounterResultItem{{InstanceName: "Ethernet", Value: "0"}},
+		)
+		_, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkNICUtil(plugin, connection, instanceFilter{}, "80", "90")
+		})
+		assert.Contains(t, err.Error(), "no network interfaces with a bandwidth")
+	})
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"monitoring-agent-client-check-nt-replacement/internal/httpclient"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const serveNSClientCommand = "serve-nsclient"
+
+// NSClient request numbers, as sent by check_nt.
+const (
+	nsclientClientVersion = 1
+	nsclientCPULoad       = 2
+	nsclientUptime        = 3
+	nsclientUsedDiskSpace = 4
+	nsclientProcState     = 6
+	nsclientMemUse        = 7
+	nsclientCounter       = 8
+	nsclientInstances     = 10
+)
+
+// nsclientVersion is the response to a CLIENTVERSION request.
+const nsclientVersion = "monitoring-agent-check-nt-replacement NSClient proxy"
+
+// nsclientMaximumRequestSize is the size of the buffer check_nt sends its
+// request from.
+const nsclientMaximumRequestSize = 8192
+
+// nsclientServer answers legacy NSClient requests by querying
+// monitoring-agent.
+type nsclientServer struct {
+	connection     agentConnection
+	password       string
+	stateDirectory string
+	timeout        time.Duration
+	logger         *log.Logger
+}
+
+// runServeNSClient implements the serve-nsclient command, which listens for
+// the requests check_nt sends to NSClient and answers them from
+// monitoring-agent, so that tools which only speak the NSClient protocol keep
+// working against hosts running monitoring-agent.
+func runServeNSClient(stderr io.Writer, args []string, httpClient httpclient.Interface) int {
+	flags := flag.NewFlagSet(serveNSClientCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	agent := addConnectionFlags(flags, "127.0.0.1")
+	listenAddress := flags.String("listen", ":12489", "address to listen for NSClient requests on")
+	nsclientPassword := flags.String("nsclient-password", os.Getenv("NSCLIENT_PASSWORD"), "password NSClient clients must send")
+	stateDirectory := flags.String("state-dir", defaultStateDirectory(), "directory used to keep state between requests")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	logger := log.New(stderr, "", log.LstdFlags)
+
+	if err := agent.validate(); err != nil {
+		logger.Print(err)
+		return 2
+	}
+	if *nsclientPassword == "" {
+		logger.Print("nsclient password is not set")
+		return 2
+	}
+
+	timeout, err := time.ParseDuration(*agent.timeoutString)
+	if err != nil {
+		logger.Printf("error parsing timeout value %s", err.Error())
+		return 2
+	}
+
+	connection, err := agent.connect(httpClient, timeout)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+
+	listener, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+
+	server := nsclientServer{
+		connection:     connection,
+		password:       *nsclientPassword,
+		stateDirectory: *stateDirectory,
+		timeout:        timeout,
+		logger:         logger,
+	}
+
+	logger.Printf("listening for NSClient requests on %s", listener.Addr())
+
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			logger.Print(err)
+			return 1
+		}
+		go server.serve(client)
+	}
+}
+
+// serve answers the single request check_nt sends per connection.
+func (server nsclientServer) serve(client net.Conn) {
+	defer client.Close()
+
+	client.SetDeadline(time.Now().Add(2 * server.timeout))
+
+	buffer := make([]byte, nsclientMaximumRequestSize)
+	length, err := client.Read(buffer)
+	if err != nil {
+		server.logger.Printf("error reading request from %s %s", client.RemoteAddr(), err.Error())
+		return
+	}
+
+	response, err := server.respond(strings.TrimRight(string(buffer[:length]), "\r\n\x00"))
+	if err != nil {
+		server.logger.Printf("error answering request from %s %s", client.RemoteAddr(), err.Error())
+		response = "ERROR: " + err.Error()
+	}
+
+	io.WriteString(client, response)
+}
+
+// respond answers a request in the password&command&parameters form.
+func (server nsclientServer) respond(request string) (string, error) {
+	parts := strings.Split(request, "&")
+
+	if subtle.ConstantTimeCompare([]byte(parts[0]), []byte(server.password)) != 1 {
+		return "", fmt.Errorf("Invalid password")
+	}
+	if len(parts) < 2 {
+		return "", fmt.Errorf("No command specified")
+	}
+
+	command, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("Unknown command %s", parts[1])
+	}
+	params := parts[2:]
+
+	requireParams := func(count int) error {
+		if len(params) < count {
+			return fmt.Errorf("Missing argument for command %d", command)
+		}
+		return nil
+	}
+
+	switch command {
+	case nsclientClientVersion:
+		return nsclientVersion, nil
+
+	case nsclientCPULoad:
+		if err := requireParams(1); err != nil {
+			return "", err
+		}
+		minutes, err := strconv.Atoi(params[0])
+		if err != nil || minutes <= 0 {
+			return "", fmt.Errorf("Invalid CPU load window %s", params[0])
+		}
+		historyFilePath := stateFilePath(server.stateDirectory, "cpuload", server.connection.url)
+		averages, err := cpuLoadAverages(server.connection, historyFilePath, []int{minutes})
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(averages[0], 'f', 0, 64), nil
+
+	case nsclientUptime:
+		uptime, err := server.connection.queryCounterValue(uptimeCounterPath)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(uptime, 'f', 0, 64), nil
+
+	case nsclientUsedDiskSpace:
+		if err := requireParams(1); err != nil {
+			return "", err
+		}
+		drive, err := parseDriveLetter(params[0])
+		if err != nil {
+			return "", err
+		}
+		disk, err := fetchDiskSpace(server.connection, drive)
+		if err != nil {
+			return "", err
+		}
+		if !disk.sizeKnown() {
+			return "", fmt.Errorf("The size of drive %s: cannot be determined as it is full", drive)
+		}
+		return fmt.Sprintf("%.0f&%.0f", disk.freeBytes, disk.totalBytes()), nil
+
+	case nsclientProcState:
+		if err := requireParams(2); err != nil {
+			return "", err
+		}
+		processes, err := fetchProcessStates(server.connection, params[1:])
+		if err != nil {
+			return "", err
+		}
+		exitCode, summary := procStateSummary(processes, strings.EqualFold(params[0], "ShowAll"))
+		return fmt.Sprintf("%d&%s", exitCode, summary), nil
+
+	case nsclientMemUse:
+		committed, limit, err := fetchMemoryUse(server.connection)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%.0f&%.0f", limit, committed), nil
+
+	case nsclientCounter:
+		if err := requireParams(1); err != nil {
+			return "", err
+		}
+		counter, err := counterpath.Parse(params[0])
+		if err != nil {
+			return "", err
+		}
+		value, err := server.connection.queryCounterValue(counter.String())
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+
+	case nsclientInstances:
+		if err := requireParams(1); err != nil {
+			return "", err
+		}
+		instances, err := fetchInstances(server.connection, strings.Trim(params[0], `\`), defaultInstancesCounter)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(instances, ","), nil
+	}
+
+	return "", fmt.Errorf("Unknown command %d", command)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNSClientRespond(t *testing.T) {
+	server := nsclientServer{
+		connection: newMockConnection(map[string][]CounterResultItem{
+			cpuLoadCounterPath:                {{InstanceName: "_Total", Value: "42"}},
+			uptimeCounterPath:                 {{Value: "300"}},
+			`\LogicalDisk(C:)\% Free Space`:   {{InstanceName: "C:", Value: "25"}},
+			`\LogicalDisk(C:)\Free Megabytes`: {{InstanceName: "C:", Value: "1024"}},
+			`\LogicalDisk(D:)\% Free Space`:   {{InstanceName: "D:", Value: "0"}},
+			`\LogicalDisk(D:)\Free Megabytes`: {{InstanceName: "D:", Value: "0"}},
+			memUseCommittedCounterPath:        {{Value: "6442450944"}},
+			memUseLimitCounterPath:            {{Value: "8589934592"}},
+			`\Memory\Available Bytes`:         {{Value: "2147483648"}},
+			procStateCounterPath: {
+				{InstanceName: "sqlservr", Value: "1200"},
+				{InstanceName: "svchost", Value: "800"},
+				{InstanceName: "svchost#1", Value: "804"},
+			},
+			`\Process(*)\*`: {
+				{InstanceName: "sqlservr", Value: "1"},
+				{InstanceName: "svchost", Value: "1"},
+				{InstanceName: "svchost", Value: "2"},
+			},
+		}),
+		password:       "secret",
+		stateDirectory: t.TempDir(),
+		timeout:        time.Second,
+	}
+
+	tests := []struct {
+		name             string
+		request          string
+		expectedResponse string
+		expectedError    string
+	}{
+		{"Wrong password", "guess&1", "", "Invalid password"},
+		{"Empty password", "&1", "", "Invalid password"},
+		{"No command", "secret", "", "No command specified"},
+		{"Unknown command", "secret&99", "", "Unknown command 99"},
+		{"Command which is not a number", "secret&uptime", "", "Unknown command uptime"},
+		{"CLIENTVERSION", "secret&1", nsclientVersion, ""},
+		{"CPULOAD", "secret&2&5", "42", ""},
+		{"CPULOAD without a window", "secret&2", "", "Missing argument for command 2"},
+		{"CPULOAD with an invalid window", "secret&2&0", "", "Invalid CPU load window 0"},
+		{"UPTIME", "secret&3", "300", ""},
+		{"USEDDISKSPACE as free&total", "secret&4&C", "1073741824&4294967296", ""},
+		{"USEDDISKSPACE without a drive", "secret&4", "", "Missing argument for command 4"},
+		{"USEDDISKSPACE of a full drive", "secret&4&D", "", "The size of drive D: cannot be determined as it is full"},
+		{"PROCSTATE as code&text", "secret&6&ShowAll&sqlservr.exe&svchost.exe", "0&sqlservr.exe: Running, svchost.exe: Running", ""},
+		{"PROCSTATE of a stopped process", "secret&6&ShowFail&sqlservr.exe&w3wp.exe", "2&w3wp.exe: not running", ""},
+		{"PROCSTATE without processes", "secret&6&ShowAll", "", "Missing argument for command 6"},
+		{"MEMUSE as limit&committed", "secret&7", "8589934592&6442450944", ""},
+		{"COUNTER", `secret&8&\Memory\Available Bytes`, "2147483648", ""},
+		{"COUNTER without a path", "secret&8", "", "Missing argument for command 8"},
+		{"COUNTER with an invalid path", `secret&8&\Memory(\Available Bytes`, "", "invalid counter path"},
+		{"INSTANCES", "secret&10&Process", "sqlservr,svchost", ""},
+		{"INSTANCES without an object", "secret&10", "", "Missing argument for command 10"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := server.respond(test.request)
+			assert.Equal(t, test.expectedResponse, response)
+			if test.expectedError == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/preset"
+)
+
+// presetFlags lists, for each field of a preset, the flags which set the
+// same value on the command line and so take precedence over the preset.
+var presetFlags = map[string][]string{
+	"counter":          {"counter", "l"},
+	"label":            {"label"},
+	"unit":             {"unit"},
+	"unit-from":        {"unit-from"},
+	"unit-to":          {"unit-to"},
+	"warning":          {"warning", "w"},
+	"critical":         {"critical", "c"},
+	"include-instance": {"include-instance"},
+	"exclude-instance": {"exclude-instance"},
+	"aggregate":        {"aggregate"},
+	"rate":             {"rate"},
+}
+
+// presetField is a flag and the values a preset sets it to.
+type presetField struct {
+	name   string
+	values []string
+}
+
+// loadPreset looks up a preset by name in the built-in catalog, extended by
+// the presets of file if set.
+func loadPreset(name string, file string) (preset.Preset, error) {
+	catalog := preset.Builtin()
+	if file != "" {
+		userCatalog, err := preset.LoadFile(file)
+		if err != nil {
+			return preset.Preset{}, fmt.Errorf("error loading presets %s", err.Error())
+		}
+		catalog = catalog.Merge(userCatalog)
+	}
+	return catalog.Lookup(name)
+}
+
+// applyPreset sets the flags bundled by a preset, leaving alone those given
+// on the command line so that any field of a preset can be overridden.
+func applyPreset(flags *flag.FlagSet, selected preset.Preset) error {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	set := func(field string, values ...string) error {
+		for _, name := range presetFlags[field] {
+			if given[name] {
+				return nil
+			}
+		}
+		for _, value := range values {
+			if err := flags.Set(field, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	optional := func(value string) []string {
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	}
+
+	fields := []presetField{
+		{"counter", selected.Counters},
+		{"label", optional(selected.Label)},
+		{"unit-from", optional(selected.UnitFrom)},
+		{"unit-to", optional(selected.UnitTo)},
+		{"warning", optional(selected.Warning)},
+		{"critical", optional(selected.Critical)},
+		{"include-instance", selected.IncludeInstances},
+		{"exclude-instance", selected.ExcludeInstances},
+		{"aggregate", optional(selected.Aggregate)},
+	}
+	// An empty unit is meaningful, it removes the default % unit.
+	if selected.Unit != nil {
+		fields = append(fields, presetField{"unit", []string{*selected.Unit}})
+	}
+	if selected.Rate {
+		fields = append(fields, presetField{"rate", []string{"true"}})
+	}
+
+	for _, field := range fields {
+		if err := set(field.name, field.values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
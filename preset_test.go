@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// presetTestFlags are the flags of main which presets set.
+type presetTestFlags struct {
+	counters       stringListFlag
+	labels         stringListFlag
+	units          stringListFlag
+	warnings       stringListFlag
+	criticals      stringListFlag
+	checkNtWarning stringFlag
+	unitFrom       *string
+	unitTo         *string
+	rate           *bool
+}
+
+// parsePresetFlags parses args with the flags presets set and applies the
+// preset called name.
+func parsePresetFlags(t *testing.T, name string, file string, args ...string) presetTestFlags {
+	var parsed presetTestFlags
+	flags := flag.NewFlagSet("preset", flag.ContinueOnError)
+	flags.Var(&parsed.counters, "counter", "")
+	flags.Var(&parsed.labels, "label", "")
+	flags.Var(&parsed.units, "unit", "")
+	flags.Var(&parsed.warnings, "warning", "")
+	flags.Var(&parsed.criticals, "critical", "")
+	flags.Var(&parsed.checkNtWarning, "w", "")
+	flags.Var(&stringListFlag{}, "include-instance", "")
+	flags.Var(&stringListFlag{}, "exclude-instance", "")
+	flags.String("aggregate", "", "")
+	parsed.unitFrom = flags.String("unit-from", "", "")
+	parsed.unitTo = flags.String("unit-to", "", "")
+	parsed.rate = flags.Bool("rate", false, "")
+	assert.Nil(t, flags.Parse(args))
+
+	selected, err := loadPreset(name, file)
+	assert.Nil(t, err)
+	assert.Nil(t, applyPreset(flags, selected))
+	return parsed
+}
+
+func TestApplyPreset(t *testing.T) {
+	t.Run("A preset sets every field", func(t *testing.T) {
+		parsed := parsePresetFlags(t, "cpu", "")
+		assert.Equal(t, []string{`\Processor(_Total)\% Processor Time`}, parsed.counters.values)
+		assert.Equal(t, []string{"cpu"}, parsed.labels.values)
+		assert.Equal(t, []string{"%"}, parsed.units.values)
+		assert.Equal(t, []string{"80"}, parsed.warnings.values)
+		assert.Equal(t, []string{"90"}, parsed.criticals.values)
+	})
+
+	t.Run("Flags given on the command line override the preset", func(t *testing.T) {
+		parsed := parsePresetFlags(t, "cpu", "", "-warning", "70", "-label", "processor")
+		assert.Equal(t, []string{"70"}, parsed.warnings.values)
+		assert.Equal(t, []string{"processor"}, parsed.labels.values)
+		assert.Equal(t, []string{"90"}, parsed.criticals.values)
+	})
+
+	t.Run("check_nt thresholds override the preset", func(t *testing.T) {
+		parsed := parsePresetFlags(t, "cpu", "", "-w", "70")
+		assert.Empty(t, parsed.warnings.values)
+		assert.Equal(t, "70", parsed.checkNtWarning.value)
+		assert.Equal(t, []string{"90"}, parsed.criticals.values)
+	})
+
+	t.Run("Flags override presets loaded from a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "presets.yaml")
+		assert.Nil(t, ioutil.WriteFile(file, []byte(`
+queue:
+  counters:
+    - '\PhysicalDisk(_Total)\Avg. Disk Queue Length'
+  label: queue
+  unit: ""
+  unit-from: s
+  unit-to: ms
+  warning: "2"
+  critical: "5"
+  rate: true
+`), 0644))
+
+		parsed := parsePresetFlags(t, "queue", file)
+		assert.Equal(t, []string{"queue"}, parsed.labels.values)
+		assert.Equal(t, []string{""}, parsed.units.values)
+		assert.Equal(t, "ms", *parsed.unitTo)
+		assert.True(t, *parsed.rate)
+
+		parsed = parsePresetFlags(t, "queue", file, "-warning", "3", "-label", "disk_queue", "-unit-to", "s")
+		assert.Equal(t, []string{"3"}, parsed.warnings.values)
+		assert.Equal(t, []string{"disk_queue"}, parsed.labels.values)
+		assert.Equal(t, []string{"5"}, parsed.criticals.values)
+		assert.Equal(t, "s", *parsed.unitTo)
+		assert.Equal(t, "s", *parsed.unitFrom)
+	})
+}
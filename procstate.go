@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strings"
+)
+
+const procStateCounterPath = `\Process(*)\ID Process`
+
+// processState is whether a requested process is running, along with the
+// number of instances found.
+type processState struct {
+	name      string
+	instances int
+}
+
+func (process processState) running() bool {
+	return process.instances > 0
+}
+
+func (process processState) String() string {
+	if process.running() {
+		return fmt.Sprintf("%s: Running", process.name)
+	}
+	return fmt.Sprintf("%s: not running", process.name)
+}
+
+// processInstanceName returns the name used to match a process against the
+// instances of the Process counter object, which are the image names without
+// their .exe extension or duplicate instance suffix.
+func processInstanceName(name string) string {
+	name = counterpath.ParseInstance(strings.ToLower(strings.TrimSpace(name))).Name
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// fetchProcessStates returns the state of each of the requested processes.
+func fetchProcessStates(connection agentConnection, names []string) ([]processState, error) {
+	decodedResponse, err := connection.queryCounter(procStateCounterPath)
+	if err != nil {
+		return nil, err
+	}
+
+	runningInstances := map[string]int{}
+	for _, outputValue := range decodedResponse.Results {
+		runningInstances[processInstanceName(outputValue.InstanceName)]++
+	}
+
+	processes := make([]processState, len(names))
+	for i, name := range names {
+		processes[i] = processState{
+			name:      name,
+			instances: runningInstances[processInstanceName(name)],
+		}
+	}
+
+	return processes, nil
+}
+
+// checkProcState emulates the check_nt PROCSTATE variable, going CRITICAL
+// when any of the requested processes is not running. Only the processes
+// which are not running are listed unless showAll is set.
+func checkProcState(plugin *nagios.Plugin, connection agentConnection, params string, showAll bool) error {
+	var names []string
+	for _, name := range splitCheckNtParams(params) {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("PROCSTATE requires a list of processes (i.e. sqlservr.exe,w3wp.exe)")
+	}
+
+	processes, err := fetchProcessStates(connection, names)
+	if err != nil {
+		return err
+	}
+
+	exitCode, summary := procStateSummary(processes, showAll)
+	plugin.ExitStatusCode = exitCode
+	setServiceOutput(plugin, summary)
+	return nil
+}
+
+// procStateSummary returns CRITICAL if any of the processes is not running,
+// along with a summary listing the processes which are not running, or every
+// process if showAll is set.
+func procStateSummary(processes []processState, showAll bool) (int, string) {
+	exitCode := nagios.StateOKExitCode
+
+	var listed []string
+	for _, process := range processes {
+		if !process.running() {
+			exitCode = nagios.StateCRITICALExitCode
+		}
+		if showAll || !process.running() {
+			listed = append(listed, process.String())
+		}
+	}
+
+	if len(listed) == 0 {
+		return exitCode, "All processes are running"
+	}
+
+	return exitCode, strings.Join(listed, ", ")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcState(t *testing.T) {
+	connection := newMockConnection(map[string][]CounterResultItem{
+		procStateCounterPath: {
+			{InstanceName: "svchost", Value: "812"},
+			{InstanceName: "svchost#1", Value: "904"},
+			{InstanceName: "svchost#2", Value: "1020"},
+			{InstanceName: "w3wp", Value: "4410"},
+			{InstanceName: "Idle", Value: "0"},
+		},
+	})
+
+	t.Run("Instance names are matched without their extension and duplicate suffix", func(t *testing.T) {
+		tests := map[string]string{
+			"svchost.exe": "svchost",
+			" W3WP.EXE ":  "w3wp",
+			"svchost#1":   "svchost",
+			"c#":          "c#",
+			"notepad":     "notepad",
+		}
+		for name, expected := range tests {
+			assert.Equal(t, expected, processInstanceName(name), name)
+		}
+	})
+
+	t.Run("Duplicate instances are counted as the same process", func(t *testing.T) {
+		processes, err := fetchProcessStates(connection, []string{"svchost.exe", "w3wp.exe", "sqlservr.exe"})
+		assert.Nil(t, err)
+		assert.Equal(t, []processState{
+			{name: "svchost.exe", instances: 3},
+			{name: "w3wp.exe", instances: 1},
+			{name: "sqlservr.exe", instances: 0},
+		}, processes)
+	})
+
+	t.Run("Running processes are OK", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkProcState(plugin, connection, "svchost.exe,W3WP.exe", false)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateOKExitCode, exitCode)
+		assert.Contains(t, output, "OK: All processes are running")
+	})
+
+	t.Run("A missing process is CRITICAL", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkProcState(plugin, connection, `"svchost.exe","sqlservr.exe"`, false)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateCRITICALExitCode, exitCode)
+		assert.Contains(t, output, "CRITICAL: sqlservr.exe: not running")
+		assert.NotContains(t, output, "svchost.exe")
+	})
+
+	t.Run("SHOWALL lists every process", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkProcState(plugin, connection, "svchost.exe,sqlservr.exe", true)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateCRITICALExitCode, exitCode)
+		assert.Contains(t, output, "CRITICAL: svchost.exe: Running, sqlservr.exe: not running")
+
+		output, exitCode, err = runCheck(func(plugin *nagios.Plugin) error {
+			return checkProcState(plugin, connection, "svchost.exe,w3wp.exe", true)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateOKExitCode, exitCode)
+		assert.Contains(t, output, "OK: svchost.exe: Running, w3wp.exe: Running")
+	})
+
+	t.Run("A list of processes is required", func(t *testing.T) {
+		_, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkProcState(plugin, connection, " , ", false)
+		})
+		assert.Contains(t, err.Error(), "PROCSTATE requires a list of processes")
+	})
+}
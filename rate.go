@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateSample is the last value of an instance of a cumulative counter as
+// recorded in the rate state file.
+type rateSample struct {
+	Time  time.Time
+	Value float64
+}
+
+// rateStateFilePath returns the state file keeping the previous values of a
+// check on a host, with an entry for each instance. The state is kept per
+// check rather than per counter, as the same counter may be checked more
+// than once in a run, so the check is identified by its position along with
+// its path and label.
+func rateStateFilePath(stateDirectory string, connection agentConnection, index int, check counterCheck) string {
+	return stateFilePath(stateDirectory, "rate", connection.url, strconv.Itoa(index), check.path, check.label)
+}
+
+// counterRates replaces the cumulative values of the instances with their
+// per second rate since the previous check and records the current values
+// for the next one. Instances without a usable previous value, because they
+// are new or their counter was reset, are left out and described in pending.
+//
+// A value lower than the previous one is a wraparound when counterMax is set
+// and the previous value was in the upper half of the counter, otherwise the
+// counter is taken to have been reset.
+func counterRates(statePath string, path string, instances []CounterResultItem, counterMax float64, now time.Time) (rates []CounterResultItem, pending []string, err error) {
+	previous := map[string]rateSample{}
+	err = loadState(statePath, &previous)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading rate state %s", err.Error())
+	}
+
+	current := make(map[string]rateSample, len(instances))
+	for _, instance := range instances {
+		value, err := strconv.ParseFloat(instance.Value, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("value %q returned for %s is not numeric", instance.Value, path)
+		}
+
+		name := instance.InstanceName
+		if name == "" {
+			name = path
+		}
+
+		key := strings.ToLower(instance.InstanceName)
+		current[key] = rateSample{Time: now, Value: value}
+
+		last, found := previous[key]
+		elapsed := now.Sub(last.Time).Seconds()
+		switch {
+		case !found:
+			pending = append(pending, fmt.Sprintf("%s: first sample recorded", name))
+			continue
+		case elapsed <= 0:
+			pending = append(pending, fmt.Sprintf("%s: no time has passed since the previous sample", name))
+			continue
+		}
+
+		delta := value - last.Value
+		if delta < 0 {
+			if counterMax <= 0 || last.Value > counterMax || last.Value < counterMax/2 {
+				pending = append(pending, fmt.Sprintf("%s: counter was reset", name))
+				continue
+			}
+			delta += counterMax + 1
+		}
+
+		rate := math.Round(delta/elapsed*1000) / 1000
+		instance.Value = strconv.FormatFloat(rate, 'f', -1, 64)
+		rates = append(rates, instance)
+	}
+
+	err = saveState(statePath, current)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing rate state %s", err.Error())
+	}
+
+	return rates, pending, nil
+}
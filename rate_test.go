@@ -0,0 +1,131 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterRates(t *testing.T) {
+	const path = `\Network Interface(*)\Bytes Received`
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	instance := func(name string, value string) CounterResultItem {
+		return CounterResultItem{CounterName: "Bytes Received", InstanceName: name, Value: value}
+	}
+
+	tests := []struct {
+		name            string
+		previous        map[string]rateSample
+		instances       []CounterResultItem
+		counterMax      float64
+		expectedRates   []CounterResultItem
+		expectedPending []string
+	}{
+		{
+			name:            "First run",
+			instances:       []CounterResultItem{instance("eth0", "1000")},
+			expectedPending: []string{"eth0: first sample recorded"},
+		},
+		{
+			name:          "Rate since the previous check",
+			previous:      map[string]rateSample{"eth0": {Time: now.Add(-time.Minute), Value: 1000}},
+			instances:     []CounterResultItem{instance("ETH0", "7000")},
+			expectedRates: []CounterResultItem{instance("ETH0", "100")},
+		},
+		{
+			name:          "Rates are rounded",
+			previous:      map[string]rateSample{"eth0": {Time: now.Add(-3 * time.Second), Value: 0}},
+			instances:     []CounterResultItem{instance("eth0", "10")},
+			expectedRates: []CounterResultItem{instance("eth0", "3.333")},
+		},
+		{
+			name: "New instances wait for the next check",
+			previous: map[string]rateSample{
+				"eth0": {Time: now.Add(-10 * time.Second), Value: 0},
+			},
+			instances:       []CounterResultItem{instance("eth0", "50"), instance("eth1", "50")},
+			expectedRates:   []CounterResultItem{instance("eth0", "5")},
+			expectedPending: []string{"eth1: first sample recorded"},
+		},
+		{
+			name:            "Counter reset",
+			previous:        map[string]rateSample{"eth0": {Time: now.Add(-time.Minute), Value: 5000}},
+			instances:       []CounterResultItem{instance("eth0", "100")},
+			expectedPending: []string{"eth0: counter was reset"},
+		},
+		{
+			name:          "Wraparound with -counter-max",
+			previous:      map[string]rateSample{"eth0": {Time: now.Add(-10 * time.Second), Value: 4294967195}},
+			instances:     []CounterResultItem{instance("eth0", "100")},
+			counterMax:    4294967295,
+			expectedRates: []CounterResultItem{instance("eth0", "20.1")},
+		},
+		{
+			name:            "Decrease from the lower half of -counter-max is a reset",
+			previous:        map[string]rateSample{"eth0": {Time: now.Add(-10 * time.Second), Value: 1000}},
+			instances:       []CounterResultItem{instance("eth0", "100")},
+			counterMax:      4294967295,
+			expectedPending: []string{"eth0: counter was reset"},
+		},
+		{
+			name:            "Previous value above -counter-max is a reset",
+			previous:        map[string]rateSample{"eth0": {Time: now.Add(-10 * time.Second), Value: 5000}},
+			instances:       []CounterResultItem{instance("eth0", "100")},
+			counterMax:      4096,
+			expectedPending: []string{"eth0: counter was reset"},
+		},
+		{
+			name:            "No time elapsed",
+			previous:        map[string]rateSample{"eth0": {Time: now, Value: 1000}},
+			instances:       []CounterResultItem{instance("eth0", "2000")},
+			expectedPending: []string{"eth0: no time has passed since the previous sample"},
+		},
+		{
+			name:            "Counters without instances are named after their path",
+			instances:       []CounterResultItem{instance("", "1000")},
+			expectedPending: []string{path + ": first sample recorded"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statePath := filepath.Join(t.TempDir(), "rate.json")
+			if test.previous != nil {
+				assert.Nil(t, saveState(statePath, test.previous))
+			}
+
+			rates, pending, err := counterRates(statePath, path, test.instances, test.counterMax, now)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedRates, rates)
+			assert.Equal(t, test.expectedPending, pending)
+		})
+	}
+
+	t.Run("Current values are kept for the next check", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "rate.json")
+		assert.Nil(t, saveState(statePath, map[string]rateSample{"gone": {Time: now.Add(-time.Hour), Value: 1}}))
+
+		_, _, err := counterRates(statePath, path, []CounterResultItem{instance("ETH0", "1000"), instance("", "5")}, 0, now)
+		assert.Nil(t, err)
+
+		var state map[string]rateSample
+		assert.Nil(t, loadState(statePath, &state))
+		assert.Equal(t, map[string]rateSample{
+			"eth0": {Time: now, Value: 1000},
+			"":     {Time: now, Value: 5},
+		}, state)
+
+		rates, pending, err := counterRates(statePath, path, []CounterResultItem{instance("eth0", "1600")}, 0, now.Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Equal(t, []CounterResultItem{instance("eth0", "10")}, rates)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("Values which are not numeric are reported", func(t *testing.T) {
+		_, _, err := counterRates(filepath.Join(t.TempDir(), "rate.json"), path, []CounterResultItem{instance("eth0", "n/a")}, 0, now)
+		assert.EqualError(t, err, `value "n/a" returned for \Network Interface(*)\Bytes Received is not numeric`)
+	})
+}
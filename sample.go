@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultStatistic = "avg"
+
+// sampleStatisticNames are the statistics calculated over the samples of an
+// instance, in the order their performance data is emitted.
+var sampleStatisticNames = []string{"min", "avg", "max", "stddev"}
+
+// sampleStatistics reduce the samples of an instance to a single value.
+var sampleStatistics = map[string]func(values []float64) float64{
+	"min": aggregateFunctions["min"],
+	"avg": aggregateFunctions["avg"],
+	"max": aggregateFunctions["max"],
+	// stddev is the population standard deviation.
+	"stddev": func(values []float64) float64 {
+		mean := aggregateFunctions["avg"](values)
+		total := 0.0
+		for _, value := range values {
+			total += (value - mean) * (value - mean)
+		}
+		return math.Sqrt(total / float64(len(values)))
+	},
+}
+
+// sampleStatistic is a statistic of the samples of an instance which is not
+// the one the thresholds apply to, emitted as extra performance data.
+type sampleStatistic struct {
+	name  string
+	value string
+}
+
+// validateStatistic checks the name of the statistic the thresholds apply
+// to.
+func validateStatistic(statistic string) error {
+	if _, ok := sampleStatistics[statistic]; ok {
+		return nil
+	}
+	return fmt.Errorf("statistic must be one of %s but got %q", strings.Join(sampleStatisticNames, ", "), statistic)
+}
+
+// sampleDeadlineShare is the share of the timeout left for the output after
+// the last sample, as sampling must not run into the timeout.
+const sampleDeadlineShare = 10
+
+// sampleDeadline returns when sampling has to end for a check which started
+// now, a tenth of the timeout before it is reached.
+func sampleDeadline(timeout time.Duration) time.Time {
+	return time.Now().Add(timeout - timeout/sampleDeadlineShare)
+}
+
+// sampleCounters calls query the given number of times, interval apart, and
+// replaces the value of every instance with the chosen statistic of its
+// samples. The other statistics are kept with the instance. Samples which
+// are not numeric are left out.
+//
+// No sample is started which would end after deadline, going by the slowest
+// query so far, in which case the statistics are those of the samples which
+// were taken. The number of samples taken is returned along with the results.
+// A zero deadline never ends sampling early.
+func sampleCounters(query func() ([]CounterResult, error), samples int, interval time.Duration, statistic string, deadline time.Time) ([]CounterResult, int, error) {
+	var sampled [][]CounterResult
+	var slowestQuery time.Duration
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			if !deadline.IsZero() && time.Now().Add(interval+slowestQuery).After(deadline) {
+				break
+			}
+			time.Sleep(interval)
+		}
+
+		started := time.Now()
+		results, err := query()
+		if err != nil {
+			return nil, 0, err
+		}
+		if elapsed := time.Since(started); elapsed > slowestQuery {
+			slowestQuery = elapsed
+		}
+		sampled = append(sampled, results)
+	}
+
+	reduced := make([]CounterResult, len(sampled[0]))
+	for check := range reduced {
+		var instances []CounterResultItem
+		values := map[string][]float64{}
+
+		for _, results := range sampled {
+			for _, instance := range results[check].Results {
+				key := strings.ToLower(instance.InstanceName)
+				if _, seen := values[key]; !seen {
+					instances = append(instances, instance)
+					values[key] = nil
+				}
+
+				if value, err := nagios.ParsePerfDataValue(instance.Value); err == nil {
+					values[key] = append(values[key], value)
+				}
+			}
+		}
+
+		for _, instance := range instances {
+			instanceValues := values[strings.ToLower(instance.InstanceName)]
+
+			// Without a single numeric sample the value is kept as it was
+			// returned, so that it is reported like any other such value.
+			if len(instanceValues) == 0 {
+				reduced[check].Results = append(reduced[check].Results, instance)
+				continue
+			}
+			instance.Value = formatStatistic(statistic, instanceValues)
+			for _, name := range sampleStatisticNames {
+				if name != statistic {
+					instance.statistics = append(instance.statistics, sampleStatistic{name: name, value: formatStatistic(name, instanceValues)})
+				}
+			}
+			reduced[check].Results = append(reduced[check].Results, instance)
+		}
+	}
+
+	return reduced, len(sampled), nil
+}
+
+func formatStatistic(statistic string, values []float64) string {
+	return strconv.FormatFloat(sampleStatistics[statistic](values), 'f', -1, 64)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleCounters(t *testing.T) {
+	// sequence returns a query answering with the next of values each time,
+	// for a single check with a single instance.
+	sequence := func(values ...string) (func() ([]CounterResult, error), *int) {
+		calls := 0
+		return func() ([]CounterResult, error) {
+			value := values[calls%len(values)]
+			calls++
+			return []CounterResult{{Results: []CounterResultItem{{InstanceName: "_Total", Value: value}}}}, nil
+		}, &calls
+	}
+
+	t.Run("The statistic of the samples is the value", func(t *testing.T) {
+		query, calls := sequence("2", "4", "4", "4", "5", "5", "7", "9")
+		results, taken, err := sampleCounters(query, 8, 0, "avg", time.Time{})
+		assert.Nil(t, err)
+		assert.Equal(t, 8, taken)
+		assert.Equal(t, 8, *calls)
+
+		instance := results[0].Results[0]
+		assert.Equal(t, "5", instance.Value)
+		assert.Equal(t, []sampleStatistic{{"min", "2"}, {"max", "9"}, {"stddev", "2"}}, instance.statistics)
+	})
+
+	t.Run("Samples which are not numeric are left out", func(t *testing.T) {
+		query, _ := sequence("10", "U", "20")
+		results, _, err := sampleCounters(query, 3, 0, "max", time.Time{})
+		assert.Nil(t, err)
+		assert.Equal(t, "20", results[0].Results[0].Value)
+
+		query, _ = sequence("U")
+		results, _, err = sampleCounters(query, 3, 0, "max", time.Time{})
+		assert.Nil(t, err)
+		assert.Equal(t, "U", results[0].Results[0].Value)
+		assert.Empty(t, results[0].Results[0].statistics)
+	})
+
+	t.Run("Sampling ends before the deadline", func(t *testing.T) {
+		query, calls := sequence("1", "3")
+		started := time.Now()
+		results, taken, err := sampleCounters(query, 100, 100*time.Millisecond, "avg", started.Add(250*time.Millisecond))
+		assert.Nil(t, err)
+		assert.Less(t, time.Since(started), 250*time.Millisecond)
+		assert.GreaterOrEqual(t, taken, 1)
+		assert.Less(t, taken, 100)
+		assert.Equal(t, taken, *calls)
+		assert.NotEmpty(t, results[0].Results[0].Value)
+	})
+
+	t.Run("Errors end sampling", func(t *testing.T) {
+		_, _, err := sampleCounters(func() ([]CounterResult, error) {
+			return nil, assert.AnError
+		}, 3, 0, "avg", time.Time{})
+		assert.Equal(t, assert.AnError, err)
+	})
+
+	t.Run("The deadline leaves a tenth of the timeout", func(t *testing.T) {
+		deadline := sampleDeadline(10 * time.Second)
+		assert.WithinDuration(t, time.Now().Add(9*time.Second), deadline, time.Second)
+	})
+
+	t.Run("Statistic names are validated", func(t *testing.T) {
+		assert.Nil(t, validateStatistic("stddev"))
+		assert.EqualError(t, validateStatistic("p95"), `statistic must be one of min, avg, max, stddev but got "p95"`)
+	})
+}
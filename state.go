@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultStateDirectory is where state is kept between checks unless
+// another directory is given.
+func defaultStateDirectory() string {
+	return filepath.Join(os.TempDir(), "monitoring-agent-check-nt-replacement")
+}
+
+// stateFilePath returns the path of a file within the state directory
+// holding state of a kind, such as cpuload, for a key, such as the URL of
+// the agent. The key is hashed so that every key makes a short and safe file
+// name while different keys never share a file.
+func stateFilePath(stateDirectory string, kind string, key ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(stateDirectory, kind+"-"+hex.EncodeToString(hash[:])+".json")
+}
+
+// loadState decodes a state file into value. A state file that does not exist
+// yet is not an error and leaves value untouched.
+func loadState(path string, value interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, value)
+}
+
+// saveState encodes value into a state file. The file is written alongside
+// the destination and renamed into place so that concurrent checks never
+// read a partially written file.
+func saveState(path string, value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	temporaryFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	_, err = temporaryFile.Write(content)
+	closeErr := temporaryFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporaryFile.Name())
+		return err
+	}
+
+	return os.Rename(temporaryFile.Name(), path)
+}
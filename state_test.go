@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	t.Run("State files are named after a hash of their key", func(t *testing.T) {
+		path := stateFilePath("/state", "rate", "https://host:9000/v1/os_specific", `\X(a b)\Y`)
+		assert.Equal(t, "/state", filepath.Dir(path))
+		assert.Regexp(t, `^rate-[0-9a-f]{64}\.json$`, filepath.Base(path))
+		assert.Equal(t, path, stateFilePath("/state", "rate", "https://host:9000/v1/os_specific", `\X(a b)\Y`))
+	})
+
+	t.Run("Different keys never share a state file", func(t *testing.T) {
+		paths := map[string]bool{}
+		for _, key := range [][]string{
+			{"host", `\X(a b)\Y`},
+			{"host", `\X(a_b)\Y`},
+			{"host", `\X(a-b)\Y`},
+			{"host-", `\X(a b)\Y`},
+			{"host", "-", `\X(a b)\Y`},
+		} {
+			paths[stateFilePath("/state", "rate", key...)] = true
+		}
+		assert.Len(t, paths, 5)
+	})
+
+	t.Run("Long keys make short file names", func(t *testing.T) {
+		path := stateFilePath("/state", "rate", strings.Repeat(`\Object(instance)\Counter`, 50))
+		assert.Less(t, len(filepath.Base(path)), 100)
+	})
+
+	t.Run("State is kept per check", func(t *testing.T) {
+		connection := newMockConnection(nil)
+		check := counterCheck{path: `\Network Interface(*)\Bytes Received`}
+
+		first := rateStateFilePath("/state", connection, 0, check)
+		assert.Equal(t, first, rateStateFilePath("/state", connection, 0, check))
+		assert.NotEqual(t, first, rateStateFilePath("/state", connection, 1, check))
+
+		check.label = "{instance}_received"
+		assert.NotEqual(t, first, rateStateFilePath("/state", connection, 0, check))
+	})
+
+	t.Run("State round trips through its file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+		var missing map[string]int
+		assert.Nil(t, loadState(path, &missing))
+		assert.Nil(t, missing)
+
+		assert.Nil(t, saveState(path, map[string]int{"a": 1}))
+		var loaded map[string]int
+		assert.Nil(t, loadState(path, &loaded))
+		assert.Equal(t, map[string]int{"a": 1}, loaded)
+
+		matches, _ := filepath.Glob(path + ".*")
+		assert.Empty(t, matches)
+	})
+}
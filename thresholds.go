@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monitoring-agent-client-check-nt-replacement/internal/counterpath"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strings"
+)
+
+// validateThreshold reports a threshold which is not a valid range. An empty
+// threshold is valid as it disables the check.
+func validateThreshold(threshold string) error {
+	if threshold != "" && nagios.ParseRangeString(threshold) == nil {
+		return fmt.Errorf("invalid threshold %q", threshold)
+	}
+	return nil
+}
+
+// scaleThreshold converts a threshold range from one unit into another by
+// multiplying both of its ends by factor. An empty threshold stays empty.
+func scaleThreshold(threshold string, factor float64) (string, error) {
+	if threshold == "" {
+		return "", nil
+	}
+
+	parsedRange := nagios.ParseRangeString(threshold)
+	if parsedRange == nil {
+		return "", fmt.Errorf("invalid threshold %q", threshold)
+	}
+
+	return parsedRange.Scale(factor).String(), nil
+}
+
+// defaultThresholdPattern is the instance pattern of a threshold given
+// without one, which applies to every instance.
+const defaultThresholdPattern = "*"
+
+// instanceThreshold is a threshold which applies to the instances matching
+// a pattern.
+type instanceThreshold struct {
+	pattern   string
+	threshold string
+}
+
+// thresholdRules select the threshold used for each instance of a counter.
+// They are written as a single range applying to every instance (i.e. 10:)
+// or as a comma separated list of instance=range pairs (i.e.
+// C:=20:,D:=5:,*=10:) where the instance may be a wildcard pattern.
+type thresholdRules []instanceThreshold
+
+// parseThresholdRules parses the value of -warning or -critical. Ranges
+// never contain an equals sign, so its presence tells the two forms apart.
+func parseThresholdRules(value string) (thresholdRules, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if !strings.Contains(value, "=") {
+		if nagios.ParseRangeString(value) == nil {
+			return nil, fmt.Errorf("invalid threshold %q", value)
+		}
+		return thresholdRules{{pattern: defaultThresholdPattern, threshold: value}}, nil
+	}
+
+	var rules thresholdRules
+	for _, entry := range strings.Split(value, ",") {
+		rule, err := parseInstanceThreshold(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseInstanceThreshold parses a single instance=range pair.
+func parseInstanceThreshold(entry string) (instanceThreshold, error) {
+	separator := strings.LastIndex(entry, "=")
+	if separator <= 0 {
+		return instanceThreshold{}, fmt.Errorf("threshold %q is not in the form instance=range", entry)
+	}
+
+	rule := instanceThreshold{
+		pattern:   strings.TrimSpace(entry[:separator]),
+		threshold: strings.TrimSpace(entry[separator+1:]),
+	}
+	if rule.threshold != "" && nagios.ParseRangeString(rule.threshold) == nil {
+		return instanceThreshold{}, fmt.Errorf("invalid threshold %q", rule.threshold)
+	}
+	return rule, nil
+}
+
+// keyed reports whether any of the rules is for specific instances.
+func (rules thresholdRules) keyed() bool {
+	for _, rule := range rules {
+		if rule.pattern != defaultThresholdPattern {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the threshold for an instance along with the pattern of the
+// rule it came from. A rule naming the instance exactly is preferred,
+// otherwise the first matching pattern is used. Instance names are matched
+// ignoring case.
+func (rules thresholdRules) lookup(instanceName string) (string, string) {
+	for _, rule := range rules {
+		if strings.EqualFold(rule.pattern, instanceName) {
+			return rule.threshold, rule.pattern
+		}
+	}
+
+	for _, rule := range rules {
+		if counterpath.Match(rule.pattern, instanceName) {
+			return rule.threshold, rule.pattern
+		}
+	}
+
+	return "", ""
+}
+
+// loadThresholdFile reads warning and critical rules from a file with a line
+// per instance in the form instance=warning,critical (i.e. C:=20:,10:).
+// Either range may be left empty. Blank lines and lines starting with # are
+// ignored.
+func loadThresholdFile(filePath string) (thresholdRules, thresholdRules, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading threshold file %s", err.Error())
+	}
+
+	var warnings thresholdRules
+	var criticals thresholdRules
+
+	for number, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		separator := strings.LastIndex(line, "=")
+		ranges := strings.Split(line[separator+1:], ",")
+		if separator <= 0 || len(ranges) != 2 {
+			return nil, nil, fmt.Errorf("%s:%d is not in the form instance=warning,critical", filePath, number+1)
+		}
+
+		instance := line[:separator]
+		warning, err := parseInstanceThreshold(instance + "=" + ranges[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d %s", filePath, number+1, err.Error())
+		}
+		critical, err := parseInstanceThreshold(instance + "=" + ranges[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d %s", filePath, number+1, err.Error())
+		}
+
+		warnings = append(warnings, warning)
+		criticals = append(criticals, critical)
+	}
+
+	return warnings, criticals, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdRules(t *testing.T) {
+	t.Run("A single range applies to every instance", func(t *testing.T) {
+		rules, err := parseThresholdRules("10:")
+		assert.Nil(t, err)
+		assert.False(t, rules.keyed())
+
+		threshold, pattern := rules.lookup("C:")
+		assert.Equal(t, "10:", threshold)
+		assert.Equal(t, defaultThresholdPattern, pattern)
+	})
+
+	t.Run("Exact names are preferred over earlier patterns", func(t *testing.T) {
+		rules, err := parseThresholdRules("c*=30:, C:=20:,D?=15:,*=10:")
+		assert.Nil(t, err)
+		assert.True(t, rules.keyed())
+
+		tests := []struct {
+			instance          string
+			expectedThreshold string
+			expectedPattern   string
+		}{
+			{"c:", "20:", "C:"},
+			{"cache", "30:", "c*"},
+			{"D:", "15:", "D?"},
+			{"E:", "10:", "*"},
+			{"svchost/1", "10:", "*"},
+		}
+		for _, test := range tests {
+			threshold, pattern := rules.lookup(test.instance)
+			assert.Equal(t, test.expectedThreshold, threshold, test.instance)
+			assert.Equal(t, test.expectedPattern, pattern, test.instance)
+		}
+	})
+
+	t.Run("Instances without a matching rule have no threshold", func(t *testing.T) {
+		rules, err := parseThresholdRules("C:=20:")
+		assert.Nil(t, err)
+
+		threshold, pattern := rules.lookup("D:")
+		assert.Equal(t, "", threshold)
+		assert.Equal(t, "", pattern)
+	})
+
+	t.Run("Invalid rules are reported", func(t *testing.T) {
+		for _, value := range []string{"abc", "C:=abc", "=10:"} {
+			_, err := parseThresholdRules(value)
+			assert.NotNil(t, err, value)
+		}
+	})
+
+	t.Run("Thresholds are scaled and validated", func(t *testing.T) {
+		scaled, err := scaleThreshold("@80:", 2)
+		assert.Nil(t, err)
+		assert.Equal(t, "@160:", scaled)
+
+		_, err = scaleThreshold("abc", 2)
+		assert.EqualError(t, err, `invalid threshold "abc"`)
+
+		assert.Nil(t, validateThreshold(""))
+		assert.Nil(t, validateThreshold("@~:10"))
+		assert.NotNil(t, validateThreshold("10:5"))
+	})
+}
+
+func TestThresholdFile(t *testing.T) {
+	writeFile := func(t *testing.T, content string) string {
+		filePath := filepath.Join(t.TempDir(), "thresholds.txt")
+		assert.Nil(t, ioutil.WriteFile(filePath, []byte(content), 0644))
+		return filePath
+	}
+
+	t.Run("A line per instance sets both ranges", func(t *testing.T) {
+		warnings, criticals, err := loadThresholdFile(writeFile(t, "# volumes\n\nC:=20:,10:\r\n  D:=,5:\nHarddisk*=15:,\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, thresholdRules{{"C:", "20:"}, {"D:", ""}, {"Harddisk*", "15:"}}, warnings)
+		assert.Equal(t, thresholdRules{{"C:", "10:"}, {"D:", "5:"}, {"Harddisk*", ""}}, criticals)
+	})
+
+	t.Run("Invalid lines are reported with their number", func(t *testing.T) {
+		filePath := writeFile(t, "C:=20:,10:\nD:=20:\n")
+		_, _, err := loadThresholdFile(filePath)
+		assert.EqualError(t, err, filePath+":2 is not in the form instance=warning,critical")
+
+		filePath = writeFile(t, "# comment\nC:=20:,x\n")
+		_, _, err = loadThresholdFile(filePath)
+		assert.EqualError(t, err, filePath+`:2 invalid threshold "x"`)
+	})
+
+	t.Run("A missing file is reported", func(t *testing.T) {
+		_, _, err := loadThresholdFile(filepath.Join(t.TempDir(), "missing.txt"))
+		assert.Contains(t, err.Error(), "error reading threshold file")
+	})
+}
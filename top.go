@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// topInstances returns the n instances with the highest values, highest
+// first. Instances with values which are not numeric are ranked last.
+func topInstances(instances []CounterResultItem, n int) []CounterResultItem {
+	type rankedInstance struct {
+		instance CounterResultItem
+		value    float64
+		numeric  bool
+	}
+
+	ranked := make([]rankedInstance, len(instances))
+	for i, instance := range instances {
+		value, err := strconv.ParseFloat(instance.Value, 64)
+		ranked[i] = rankedInstance{instance: instance, value: value, numeric: err == nil}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].numeric != ranked[j].numeric {
+			return ranked[i].numeric
+		}
+		return ranked[i].value > ranked[j].value
+	})
+
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+
+	top := make([]CounterResultItem, n)
+	for i := range top {
+		top[i] = ranked[i].instance
+	}
+	return top
+}
+
+// topReport returns a ranked table of the top instances of a counter for the
+// long output, along with the state of each of them.
+func topReport(check counterCheck, top []CounterResultItem, total int, unit string, conversion *unitConversion) []string {
+	lines := []string{fmt.Sprintf("Top %d of %d instances of %s:", len(top), total, check.path)}
+
+	for i, instance := range top {
+		warning, _ := check.warning.lookup(instance.InstanceName)
+		critical, _ := check.critical.lookup(instance.InstanceName)
+		lines = append(lines, fmt.Sprintf(
+			"%d. %s: %s (%s)",
+			i+1,
+			describeInstance(instance.InstanceName),
+			conversion.format(instance.Value, unit),
+			thresholdState(conversion.perfDataValue(instance.Value), warning, critical),
+		))
+	}
+
+	return lines
+}
+
+// thresholdState returns the label of the state a value is in, given its
+// thresholds.
+func thresholdState(value string, warning string, critical string) string {
+	switch {
+	case critical != "" && nagios.ParseRangeString(critical).CheckRange(value):
+		return nagios.StateCRITICALLabel
+	case warning != "" && nagios.ParseRangeString(warning).CheckRange(value):
+		return nagios.StateWARNINGLabel
+	default:
+		return nagios.StateOKLabel
+	}
+}
+
+// topNames returns the lower-cased names of the top instances.
+func topNames(top []CounterResultItem) map[string]bool {
+	names := make(map[string]bool, len(top))
+	for _, instance := range top {
+		names[strings.ToLower(instance.InstanceName)] = true
+	}
+	return names
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopInstances(t *testing.T) {
+	processes := []CounterResultItem{
+		{InstanceName: "idle", Value: "n/a"},
+		{InstanceName: "sqlservr", Value: "45"},
+		{InstanceName: "w3wp", Value: "80"},
+		{InstanceName: "w3wp#1", Value: "45"},
+		{InstanceName: "svchost", Value: "2"},
+	}
+
+	names := func(instances []CounterResultItem) []string {
+		var names []string
+		for _, instance := range instances {
+			names = append(names, instance.InstanceName)
+		}
+		return names
+	}
+
+	t.Run("Instances are ranked by value, keeping the order of ties", func(t *testing.T) {
+		assert.Equal(t, []string{"w3wp", "sqlservr", "w3wp#1"}, names(topInstances(processes, 3)))
+	})
+
+	t.Run("Values which are not numeric are ranked last", func(t *testing.T) {
+		assert.Equal(t, []string{"w3wp", "sqlservr", "w3wp#1", "svchost", "idle"}, names(topInstances(processes, 10)))
+	})
+
+	t.Run("The report ranks the instances with their state", func(t *testing.T) {
+		warning, _ := parseThresholdRules("50")
+		critical, _ := parseThresholdRules("w3wp*=40,*=70")
+		check := counterCheck{path: `\Process(*)\% Processor Time`, warning: warning, critical: critical}
+
+		assert.Equal(t, []string{
+			`Top 3 of 5 instances of \Process(*)\% Processor Time:`,
+			"1. w3wp: 80% (CRITICAL)",
+			"2. sqlservr: 45% (OK)",
+			"3. w3wp#1: 45% (CRITICAL)",
+		}, topReport(check, topInstances(processes, 3), len(processes), "%", nil))
+	})
+
+	t.Run("States follow the thresholds", func(t *testing.T) {
+		assert.Equal(t, nagios.StateCRITICALLabel, thresholdState("95", "80", "90"))
+		assert.Equal(t, nagios.StateWARNINGLabel, thresholdState("85", "80", "90"))
+		assert.Equal(t, nagios.StateOKLabel, thresholdState("75", "80", "90"))
+		assert.Equal(t, nagios.StateOKLabel, thresholdState("75", "", ""))
+	})
+
+	t.Run("Only the top instances have performance data if requested", func(t *testing.T) {
+		connection := newMockConnection(map[string][]CounterResultItem{`\Process(*)\% Processor Time`: processes[1:]})
+		checks, err := newCounterChecks(newCounterFlags(`\Process(*)\% Processor Time`), newCounterFlags(), newCounterFlags(), defaultCounterUnit, newCounterFlags("50"), newCounterFlags())
+		assert.Nil(t, err)
+		assert.Nil(t, parseCounterPaths(checks))
+
+		options := testCounterOptions()
+		options.top = 2
+		options.topPerfData = true
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkCounters(plugin, connection, checks, options)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateWARNINGExitCode, exitCode)
+		assert.Contains(t, output, "1. w3wp: 80% (WARNING)")
+		assert.Contains(t, output, "| 'sqlservr'=45%;50;;; 'w3wp'=80%;50;;;")
+		assert.NotContains(t, output, "'svchost'")
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+	"strings"
+)
+
+const uptimeCounterPath = `\System\System Up Time`
+
+// uptimeUnits are the units UPTIME thresholds can be given in, in seconds.
+var uptimeUnits = map[string]float64{
+	"seconds": 1,
+	"minutes": 60,
+	"hours":   60 * 60,
+	"days":    24 * 60 * 60,
+}
+
+const defaultUptimeUnit = "minutes"
+
+// checkUptime emulates the check_nt UPTIME variable. Thresholds are given in
+// the selected unit and converted to seconds for the performance data, so a
+// warning threshold of 10: with the default unit alerts when the system has
+// been up for less than 10 minutes.
+func checkUptime(plugin *nagios.Plugin, connection agentConnection, unit string, warningThreshold string, criticalThreshold string) error {
+	if unit == "" {
+		unit = defaultUptimeUnit
+	}
+
+	secondsPerUnit, ok := uptimeUnits[strings.ToLower(unit)]
+	if !ok {
+		return fmt.Errorf("UPTIME unit must be one of seconds, minutes, hours or days but got %q", unit)
+	}
+
+	warning, err := scaleThreshold(warningThreshold, secondsPerUnit)
+	if err != nil {
+		return err
+	}
+	critical, err := scaleThreshold(criticalThreshold, secondsPerUnit)
+	if err != nil {
+		return err
+	}
+
+	uptime, err := connection.queryCounterValue(uptimeCounterPath)
+	if err != nil {
+		return err
+	}
+
+	perfdata := nagios.PerformanceData{
+		Label:             "uptime",
+		Value:             strconv.FormatFloat(uptime, 'f', 0, 64),
+		UnitOfMeasurement: "s",
+		Warn:              warning,
+		Crit:              critical,
+		Min:               "0",
+	}
+	plugin.AddPerfData(false, perfdata)
+	plugin.EvaluateThreshold(perfdata)
+
+	seconds := int64(uptime)
+	setServiceOutput(plugin, fmt.Sprintf(
+		"System Uptime - %d day(s) %d hour(s) %d minute(s)",
+		seconds/86400,
+		seconds%86400/3600,
+		seconds%3600/60,
+	))
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUptime(t *testing.T) {
+	uptime := func(seconds string) agentConnection {
+		return newMockConnection(map[string][]CounterResultItem{
+			uptimeCounterPath: {{CounterName: "System Up Time", Value: seconds}},
+		})
+	}
+
+	t.Run("The uptime is written in days, hours and minutes", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkUptime(plugin, uptime("93784.6"), "", "", "")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateOKExitCode, exitCode)
+		assert.Contains(t, output, "OK: System Uptime - 1 day(s) 2 hour(s) 3 minute(s)")
+		assert.Contains(t, output, "'uptime'=93785s;;;0;")
+	})
+
+	t.Run("Thresholds are converted from the unit to seconds", func(t *testing.T) {
+		tests := []struct {
+			unit             string
+			expectedWarning  string
+			expectedCritical string
+		}{
+			{"", "@~:600", "@~:300"},
+			{"seconds", "@~:10", "@~:5"},
+			{"Minutes", "@~:600", "@~:300"},
+			{"hours", "@~:36000", "@~:18000"},
+			{"DAYS", "@~:864000", "@~:432000"},
+		}
+
+		for _, test := range tests {
+			output, _, err := runCheck(func(plugin *nagios.Plugin) error {
+				return checkUptime(plugin, uptime("93784"), test.unit, "@~:10", "@~:5")
+			})
+			assert.Nil(t, err, test.unit)
+			assert.Contains(t, output, "'uptime'=93784s;"+test.expectedWarning+";"+test.expectedCritical+";0;", test.unit)
+		}
+	})
+
+	t.Run("A recent reboot alerts", func(t *testing.T) {
+		warning, critical := translateCheckNtThresholds(checkNtVariableUptime, "10", "5")
+
+		tests := []struct {
+			seconds          string
+			expectedExitCode int
+		}{
+			{"3600", nagios.StateOKExitCode},
+			{"601", nagios.StateOKExitCode},
+			{"600", nagios.StateWARNINGExitCode},
+			{"420", nagios.StateWARNINGExitCode},
+			{"300", nagios.StateCRITICALExitCode},
+			{"60", nagios.StateCRITICALExitCode},
+		}
+
+		for _, test := range tests {
+			_, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+				return checkUptime(plugin, uptime(test.seconds), "", warning, critical)
+			})
+			assert.Nil(t, err, test.seconds)
+			assert.Equal(t, test.expectedExitCode, exitCode, test.seconds)
+		}
+	})
+
+	t.Run("Unknown units and invalid thresholds are errors", func(t *testing.T) {
+		_, _, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkUptime(plugin, uptime("60"), "weeks", "", "")
+		})
+		assert.EqualError(t, err, `UPTIME unit must be one of seconds, minutes, hours or days but got "weeks"`)
+
+		_, _, err = runCheck(func(plugin *nagios.Plugin) error {
+			return checkUptime(plugin, uptime("60"), "", "abc", "")
+		})
+		assert.EqualError(t, err, `invalid threshold "abc"`)
+	})
+}
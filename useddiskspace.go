@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+	"strconv"
+	"strings"
+)
+
+const bytesPerGigabyte = 1024 * 1024 * 1024
+
+// diskSpace is the free space of a logical disk. The agent does not expose
+// the size of a disk directly, so it is derived from the free space and the
+// free percentage; a completely full disk has an unknown size.
+type diskSpace struct {
+	freeBytes   float64
+	freePercent float64
+}
+
+func (disk diskSpace) sizeKnown() bool {
+	return disk.freePercent > 0
+}
+
+func (disk diskSpace) totalBytes() float64 {
+	return disk.freeBytes / (disk.freePercent / 100)
+}
+
+func (disk diskSpace) usedBytes() float64 {
+	return disk.totalBytes() - disk.freeBytes
+}
+
+func (disk diskSpace) usedPercent() float64 {
+	return 100 - disk.freePercent
+}
+
+// parseDriveLetter accepts a drive as check_nt does (i.e. C) as well as the
+// C: and C:\ forms.
+func parseDriveLetter(params string) (string, error) {
+	drive := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(params), `\`), ":")
+	if len(drive) != 1 || !strings.ContainsAny(strings.ToUpper(drive), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		return "", fmt.Errorf("USEDDISKSPACE requires a drive letter (i.e. C) but got %q", params)
+	}
+	return strings.ToUpper(drive), nil
+}
+
+// fetchDiskSpace returns the free space of the given drive letter.
+func fetchDiskSpace(connection agentConnection, drive string) (diskSpace, error) {
+	freePercent, err := connection.queryCounterValue(fmt.Sprintf(`\LogicalDisk(%s:)\%% Free Space`, drive))
+	if err != nil {
+		return diskSpace{}, err
+	}
+
+	freeMegabytes, err := connection.queryCounterValue(fmt.Sprintf(`\LogicalDisk(%s:)\Free Megabytes`, drive))
+	if err != nil {
+		return diskSpace{}, err
+	}
+
+	return diskSpace{
+		freeBytes:   freeMegabytes * bytesPerMegabyte,
+		freePercent: freePercent,
+	}, nil
+}
+
+// checkUsedDiskSpace emulates the check_nt USEDDISKSPACE variable.
+// Thresholds are given as a percentage of used space and are converted to
+// bytes for the performance data.
+func checkUsedDiskSpace(plugin *nagios.Plugin, connection agentConnection, params string, warningThreshold string, criticalThreshold string) error {
+	drive, err := parseDriveLetter(params)
+	if err != nil {
+		return err
+	}
+
+	for _, threshold := range []string{warningThreshold, criticalThreshold} {
+		if err := validateThreshold(threshold); err != nil {
+			return err
+		}
+	}
+
+	disk, err := fetchDiskSpace(connection, drive)
+	if err != nil {
+		return err
+	}
+
+	if !disk.sizeKnown() {
+		plugin.EvaluateThreshold(nagios.PerformanceData{
+			Label: "used",
+			Value: strconv.FormatFloat(disk.usedPercent(), 'f', 2, 64),
+			Warn:  warningThreshold,
+			Crit:  criticalThreshold,
+		})
+		setServiceOutput(plugin, fmt.Sprintf(`%s:\ - total: unknown - used: unknown (100%%) - free 0.00 Gb (0%%)`, drive))
+		return nil
+	}
+
+	total := disk.totalBytes()
+
+	warning, err := scaleThreshold(warningThreshold, total/100)
+	if err != nil {
+		return err
+	}
+	critical, err := scaleThreshold(criticalThreshold, total/100)
+	if err != nil {
+		return err
+	}
+
+	perfdata := nagios.PerformanceData{
+		Label:             fmt.Sprintf(`%s:\ Used Space`, drive),
+		Value:             strconv.FormatFloat(disk.usedBytes(), 'f', 0, 64),
+		UnitOfMeasurement: "B",
+		Warn:              warning,
+		Crit:              critical,
+		Min:               "0",
+		Max:               strconv.FormatFloat(total, 'f', 0, 64),
+	}
+	plugin.AddPerfData(false, perfdata)
+	plugin.EvaluateThreshold(perfdata)
+
+	setServiceOutput(plugin, fmt.Sprintf(
+		`%s:\ - total: %.2f Gb - used: %.2f Gb (%.0f%%) - free %.2f Gb (%.0f%%)`,
+		drive,
+		total/bytesPerGigabyte,
+		disk.usedBytes()/bytesPerGigabyte,
+		disk.usedPercent(),
+		disk.freeBytes/bytesPerGigabyte,
+		disk.freePercent,
+	))
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"monitoring-agent-client-check-nt-replacement/internal/nagios"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsedDiskSpace(t *testing.T) {
+	disk := func(freePercent string, freeMegabytes string) agentConnection {
+		return newMockConnection(map[string][]CounterResultItem{
+			`\LogicalDisk(C:)\% Free Space`:   {{InstanceName: "C:", Value: freePercent}},
+			`\LogicalDisk(C:)\Free Megabytes`: {{InstanceName: "C:", Value: freeMegabytes}},
+		})
+	}
+
+	t.Run("Thresholds are converted to bytes", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkUsedDiskSpace(plugin, disk("20", "1024"), "c", "@80:", "@90:")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateWARNINGExitCode, exitCode)
+		assert.Contains(t, output, `'C:\ Used Space'=4294967296B;@4294967296:;@4831838208:;0;5368709120`)
+	})
+
+	t.Run("A full disk is checked against the used percentage", func(t *testing.T) {
+		output, exitCode, err := runCheck(func(plugin *nagios.Plugin) error {
+			return checkUsedDiskSpace(plugin, disk("0", "0"), "C:", "@80:", "@90:")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, nagios.StateCRITICALExitCode, exitCode)
+		assert.Contains(t, output, "total: unknown")
+	})
+
+	t.Run("Invalid thresholds are reported before checking", func(t *testing.T) {
+		for _, freePercent := range []string{"0", "20"} {
+			_, _, err := runCheck(func(plugin *nagios.Plugin) error {
+				return checkUsedDiskSpace(plugin, disk(freePercent, "0"), "C", "abc", "@90:")
+			})
+			assert.EqualError(t, err, `invalid threshold "abc"`, freePercent)
+		}
+	})
+}